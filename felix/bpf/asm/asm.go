@@ -60,6 +60,12 @@ const (
 	// to indicate a map file descriptor.
 	RPseudoMapFD = 1
 
+	// RPseudoCall is the special source register value used with Call to indicate a BPF-to-BPF
+	// subprogram call (imm is then a relative instruction offset to the callee rather than a
+	// helper ID). Shares the same numeric value as RPseudoMapFD because the two only ever appear
+	// on different instruction shapes (LoadImm64 vs Call).
+	RPseudoCall = 1
+
 	// Opcode parts.
 
 	// Lowest 3 bits of opcode are the instruction class.
@@ -79,6 +85,21 @@ const (
 	MemOpModeInd  = 0b010_00_000 // Carry over from cBPF, non-general-purpose
 	MemOpModeMem  = 0b011_00_000 // eBPF general memory op.
 	MemOpModeXADD = 0b110_00_000 // eBPF general memory op.
+	MemOpModeAtomic = MemOpModeXADD // Alias used by the kernel ABI docs for the post-5.12 atomic family.
+
+	// AtomicOpFetch is OR'd into the imm field of an atomic instruction to request the
+	// "fetch" variant, which additionally loads the pre-modification value back into src.
+	AtomicOpFetch = 0x01
+
+	// Atomic sub-operations, packed into the imm field of a BPF_ATOMIC instruction. Values
+	// match the kernel's BPF_ADD/BPF_OR/BPF_AND/BPF_XOR/BPF_XCHG/BPF_CMPXCHG. BPF_XCHG and
+	// BPF_CMPXCHG always imply AtomicOpFetch, per the kernel ABI.
+	AtomicOpAdd     = 0x00
+	AtomicOpOr      = 0x40
+	AtomicOpAnd     = 0x50
+	AtomicOpXor     = 0xa0
+	AtomicOpXchg    = 0xe0 | AtomicOpFetch
+	AtomicOpCmpXchg = 0xf0 | AtomicOpFetch
 
 	// For memory operations, the middle two bits are the size modifier.
 	MemOpSize8  = 0b000_10_000
@@ -139,6 +160,12 @@ const (
 	StoreImm32 OpCode = OpClassStoreImm | MemOpModeImm | MemOpSize32
 	StoreImm64 OpCode = OpClassStoreImm | MemOpModeImm | MemOpSize64
 
+	// Atomic opcodes (BPF_ATOMIC mode, landed in kernel 5.12). The specific operation
+	// (add/or/and/xor/xchg/cmpxchg, fetching or not) is encoded in the instruction's imm
+	// field, not the opcode, so there's only one opcode per size for all of them.
+	Atomic32 OpCode = OpClassStoreReg | MemOpModeAtomic | MemOpSize32
+	Atomic64 OpCode = OpClassStoreReg | MemOpModeAtomic | MemOpSize64
+
 	LoadReg8  OpCode = OpClassLoadReg | MemOpModeMem | MemOpSize8
 	LoadReg16 OpCode = OpClassLoadReg | MemOpModeMem | MemOpSize16
 	LoadReg32 OpCode = OpClassLoadReg | MemOpModeMem | MemOpSize32
@@ -185,6 +212,13 @@ const (
 	// JumpA: Unconditional jump.
 	JumpA OpCode = OpClassJump64 | ALUSrcImm | JumpOpA
 
+	// LongJumpA is the kernel's "gotol" long-range unconditional jump: same BPF_JA operation as
+	// JumpA, but BPF_JMP32-classed, which the kernel (since the gotol/gotol32 additions) takes as a
+	// signal to read the jump target out of the 32-bit Imm field instead of the 16-bit Off field.
+	// Only materialised by resolveLongJumpOverflows, when SetLongJumpsEnabled is on and a jump's
+	// offset doesn't fit in int16; never emitted directly by the builder methods.
+	LongJumpA OpCode = OpClassJump32 | ALUSrcImm | JumpOpA
+
 	// Call calls the helper function with ID stored in the immediate.
 	Call OpCode = OpClassJump64 | ALUSrcImm | JumpOpCall
 	// Exit exits the program, has no arguments, the return value is in R0.
@@ -382,6 +416,52 @@ type Block struct {
 	deferredErr        error
 	NumJumps           int
 	trampolineStride   int
+
+	// longJumpsEnabled gates resolveLongJumpOverflows, which runs before any fix-up is baked into
+	// final instruction bytes and materialises a LongJumpA (32-bit offset) in place of any jump
+	// whose resolved offset doesn't fit in int16, instead of leaving applyFixUps to fail. Off by
+	// default: it's the caller's responsibility to only turn it on once they've established
+	// (e.g. via a kernel feature probe) that the gotol encoding is supported, since there's no way
+	// for the assembler itself to detect that.
+	longJumpsEnabled bool
+
+	// subprogOrder and subprogBuilders back DefineSubprogram: each named subprogram is laid out,
+	// in definition order, contiguously after the main program's instructions when Assemble runs.
+	subprogOrder    []string
+	subprogBuilders map[string]func(*Block)
+	subprogStarts   map[string]int
+
+	// dispatchLabelIdx numbers the internal labels BinarySearchDispatch generates for its tree's
+	// split points, so repeated calls on the same Block don't collide.
+	dispatchLabelIdx int
+
+	// labelPrefix and exitMap implement InlineFragment's label rewriting: while non-empty/non-nil,
+	// every label name the public Jump*/LabelNextInsn methods see is resolved via resolveLabel
+	// before it reaches the fixUps/labelToInsnIdx machinery, rather than being used as-is. Both are
+	// saved and restored around each InlineFragment call, so nested inlining (a fragment inlining
+	// another) composes correctly.
+	labelPrefix string
+	exitMap     map[string]string
+	// fragmentInlineIdx numbers InlineFragment call sites, so each gets a unique label suffix.
+	fragmentInlineIdx int
+}
+
+// resolveLabel is the single choke point InlineFragment's rewriting goes through: an exit name is
+// redirected to the caller-supplied target, anything else gets the active label prefix (which is
+// "" outside of an InlineFragment call, making this a no-op the rest of the time).
+func (b *Block) resolveLabel(label string) string {
+	if target, ok := b.exitMap[label]; ok {
+		return target
+	}
+	return b.labelPrefix + label
+}
+
+// ResolveLabel exposes resolveLabel for fragment build functions that compose: it turns one of the
+// build function's own local label names into the final, absolute name it resolves to in the
+// current InlineFragment call, which is what's needed to pass that label as an exit target of a
+// nested InlineFragment call.
+func (b *Block) ResolveLabel(label string) string {
+	return b.resolveLabel(label)
 }
 
 func NewBlock(policyDebugEnabled bool) *Block {
@@ -394,11 +474,16 @@ func NewBlock(policyDebugEnabled bool) *Block {
 		fixUps:             map[string][]fixUp{},
 		trampolinesEnabled: true,
 		trampolineStride:   TrampolineStrideDefault,
+		subprogBuilders:    map[string]func(*Block){},
+		subprogStarts:      map[string]int{},
 	}
 }
 
 type fixUp struct {
 	origInsnIdx int
+	// useImm is set for BPF-to-BPF call fixups: the resolved relative offset is written into the
+	// 32-bit imm field (kernel ABI for BPF_PSEUDO_CALL) rather than the usual 16-bit jump offset.
+	useImm bool
 }
 
 func (b *Block) NoOp() {
@@ -489,6 +574,74 @@ func (b *Block) Store64(dst Reg, ptrReg Reg, fo FieldOffset) {
 	b.add(StoreReg64, dst, ptrReg, fo.Offset, 0, annotation)
 }
 
+// atomic emits a BPF_ATOMIC instruction: "lock" the memory at *(size *)(ptrReg+fo.Offset) and
+// apply subOp using src, per the kernel's atomic instruction family (kernel >= 5.12). src is the
+// operand for add/or/and/xor, the new value for xchg, or the value to swap in for cmpxchg (with
+// R0 holding the comparand and receiving the old value, per the kernel ABI); for fetching variants
+// the pre-modification value is written back into src.
+func (b *Block) atomic(size OpCode, ptrReg, src Reg, fo FieldOffset, subOp int32) {
+	annotation := b.buildAnnotation(size, src, ptrReg, fo, subOp)
+	b.add(size, ptrReg, src, fo.Offset, subOp, annotation)
+}
+
+func (b *Block) AtomicAdd32(ptrReg, src Reg, fo FieldOffset) { b.atomic(Atomic32, ptrReg, src, fo, AtomicOpAdd) }
+func (b *Block) AtomicAdd64(ptrReg, src Reg, fo FieldOffset) { b.atomic(Atomic64, ptrReg, src, fo, AtomicOpAdd) }
+
+func (b *Block) AtomicOr32(ptrReg, src Reg, fo FieldOffset) { b.atomic(Atomic32, ptrReg, src, fo, AtomicOpOr) }
+func (b *Block) AtomicOr64(ptrReg, src Reg, fo FieldOffset) { b.atomic(Atomic64, ptrReg, src, fo, AtomicOpOr) }
+
+func (b *Block) AtomicAnd32(ptrReg, src Reg, fo FieldOffset) { b.atomic(Atomic32, ptrReg, src, fo, AtomicOpAnd) }
+func (b *Block) AtomicAnd64(ptrReg, src Reg, fo FieldOffset) { b.atomic(Atomic64, ptrReg, src, fo, AtomicOpAnd) }
+
+func (b *Block) AtomicXor32(ptrReg, src Reg, fo FieldOffset) { b.atomic(Atomic32, ptrReg, src, fo, AtomicOpXor) }
+func (b *Block) AtomicXor64(ptrReg, src Reg, fo FieldOffset) { b.atomic(Atomic64, ptrReg, src, fo, AtomicOpXor) }
+
+func (b *Block) AtomicFetchAdd32(ptrReg, src Reg, fo FieldOffset) {
+	b.atomic(Atomic32, ptrReg, src, fo, AtomicOpAdd|AtomicOpFetch)
+}
+func (b *Block) AtomicFetchAdd64(ptrReg, src Reg, fo FieldOffset) {
+	b.atomic(Atomic64, ptrReg, src, fo, AtomicOpAdd|AtomicOpFetch)
+}
+
+func (b *Block) AtomicFetchOr32(ptrReg, src Reg, fo FieldOffset) {
+	b.atomic(Atomic32, ptrReg, src, fo, AtomicOpOr|AtomicOpFetch)
+}
+func (b *Block) AtomicFetchOr64(ptrReg, src Reg, fo FieldOffset) {
+	b.atomic(Atomic64, ptrReg, src, fo, AtomicOpOr|AtomicOpFetch)
+}
+
+func (b *Block) AtomicFetchAnd32(ptrReg, src Reg, fo FieldOffset) {
+	b.atomic(Atomic32, ptrReg, src, fo, AtomicOpAnd|AtomicOpFetch)
+}
+func (b *Block) AtomicFetchAnd64(ptrReg, src Reg, fo FieldOffset) {
+	b.atomic(Atomic64, ptrReg, src, fo, AtomicOpAnd|AtomicOpFetch)
+}
+
+func (b *Block) AtomicFetchXor32(ptrReg, src Reg, fo FieldOffset) {
+	b.atomic(Atomic32, ptrReg, src, fo, AtomicOpXor|AtomicOpFetch)
+}
+func (b *Block) AtomicFetchXor64(ptrReg, src Reg, fo FieldOffset) {
+	b.atomic(Atomic64, ptrReg, src, fo, AtomicOpXor|AtomicOpFetch)
+}
+
+// AtomicXchg32/64 atomically swap src with *(size *)(ptrReg+fo.Offset), leaving the previous
+// value of the memory location in src.
+func (b *Block) AtomicXchg32(ptrReg, src Reg, fo FieldOffset) {
+	b.atomic(Atomic32, ptrReg, src, fo, AtomicOpXchg)
+}
+func (b *Block) AtomicXchg64(ptrReg, src Reg, fo FieldOffset) {
+	b.atomic(Atomic64, ptrReg, src, fo, AtomicOpXchg)
+}
+
+// AtomicCmpXchg32/64 atomically compare *(size *)(ptrReg+fo.Offset) against R0 and, if equal, set
+// it to src; R0 is always left holding the value that was read, per the kernel ABI.
+func (b *Block) AtomicCmpXchg32(ptrReg, src Reg, fo FieldOffset) {
+	b.atomic(Atomic32, ptrReg, src, fo, AtomicOpCmpXchg)
+}
+func (b *Block) AtomicCmpXchg64(ptrReg, src Reg, fo FieldOffset) {
+	b.atomic(Atomic64, ptrReg, src, fo, AtomicOpCmpXchg)
+}
+
 func (b *Block) LoadStack8(dst Reg, fo FieldOffset) {
 	b.Load8(dst, R10, fo)
 }
@@ -625,6 +778,56 @@ func (b *Block) Exit() {
 	b.add(Exit, 0, 0, 0, 0, "")
 }
 
+// subprogLabel is the internal label name marking a subprogram's entry instruction; it's never
+// exposed to callers, who only ever refer to subprograms by the name passed to DefineSubprogram.
+func subprogLabel(name string) string {
+	return "__subprog$" + name
+}
+
+// DefineSubprogram registers a BPF-to-BPF subprogram: build is called during Assemble, with a
+// *Block of its own, to emit the subprogram's instructions. Subprograms are laid out, in
+// definition order, contiguously after the main program's instructions, so CallSubprogram sites
+// can reference a subprogram defined either before or after the call site. Names must be unique
+// within a Block.
+func (b *Block) DefineSubprogram(name string, build func(*Block)) {
+	if _, ok := b.subprogBuilders[name]; ok {
+		if b.deferredErr == nil {
+			b.deferredErr = fmt.Errorf("subprogram %q defined more than once", name)
+		}
+		return
+	}
+	b.subprogBuilders[name] = build
+	b.subprogOrder = append(b.subprogOrder, name)
+}
+
+// CallSubprogram emits a BPF-to-BPF call to the subprogram registered under name via
+// DefineSubprogram. Unlike Call (which targets a fixed helper ID), the target here is resolved to
+// a relative instruction offset by Assemble, with src set to RPseudoCall per the kernel ABI.
+func (b *Block) CallSubprogram(name string) {
+	insn := MakeInsn(Call, 0, RPseudoCall, 0, 0)
+	b.addInsnWithImmFixup(insn, subprogLabel(name))
+}
+
+// SubprogramOffset returns the instruction index of the named subprogram's entry point within the
+// Insns returned by Assemble, once Assemble has run. This is the information a loader needs to
+// populate BPF_PROG_LOAD's func_info/subprog metadata for a multi-function object.
+func (b *Block) SubprogramOffset(name string) (int, bool) {
+	idx, ok := b.subprogStarts[name]
+	return idx, ok
+}
+
+// appendSubprograms lays out every DefineSubprogram'd subprogram, in definition order,
+// contiguously after whatever instructions have been added so far (i.e. after the main program).
+// It runs at the start of Assemble, before fix-ups are resolved, so CallSubprogram sites -- in the
+// main program or in another subprogram -- can be patched up regardless of definition order.
+func (b *Block) appendSubprograms() {
+	for _, name := range b.subprogOrder {
+		b.labelNextInsnExact(subprogLabel(name))
+		b.subprogStarts[name] = len(b.insns)
+		b.subprogBuilders[name](b)
+	}
+}
+
 func (b *Block) add(opcode OpCode, dst, src Reg, offset int16, imm int32, annotation string) Insn {
 	insn := MakeInsn(opcode, dst, src, offset, imm)
 	insn.Annotation = annotation
@@ -638,7 +841,7 @@ func (b *Block) Instr(opcode OpCode, dst, src Reg, offset int16, imm int32, anno
 
 func (b *Block) addWithOffsetFixup(opcode OpCode, dst, src Reg, offsetLabel string, imm int32) Insn {
 	insn := MakeInsn(opcode, dst, src, 0, imm)
-	b.addInsnWithOffsetFixup(insn, offsetLabel)
+	b.addInsnWithOffsetFixup(insn, b.resolveLabel(offsetLabel))
 	return insn
 }
 
@@ -660,9 +863,9 @@ func (b *Block) buildAnnotation(opcode OpCode, src, dst Reg, fo FieldOffset, imm
 		cast = "u8"
 	case StoreReg16, LoadReg16, StoreImm16:
 		cast = "u16"
-	case StoreReg32, LoadReg32, StoreImm32:
+	case StoreReg32, LoadReg32, StoreImm32, Atomic32:
 		cast = "u32"
-	case StoreReg64, LoadReg64, StoreImm64, LoadImm64:
+	case StoreReg64, LoadReg64, StoreImm64, LoadImm64, Atomic64:
 		cast = "u64"
 	}
 
@@ -676,10 +879,28 @@ func (b *Block) buildAnnotation(opcode OpCode, src, dst Reg, fo FieldOffset, imm
 		regStr = fmt.Sprintf("*(%s *) (%s + %d) /* %s */ = %d", cast, dst, fo.Offset, fo.Field, imm)
 	case Call:
 		regStr = fmt.Sprintf("call %s", HelperString[imm])
+	case Atomic32, Atomic64:
+		regStr = fmt.Sprintf("lock *(%s *)(%s + %d) /* %s */ = %s(%s)", cast, dst, fo.Offset, fo.Field,
+			atomicSubOpString[imm], src)
 	}
 	return regStr
 }
 
+// atomicSubOpString gives the builder-style function name used in debug annotations for each
+// atomic sub-op, e.g. "lock *(u64*)(r1+8) = atomic_fetch_add(r2)".
+var atomicSubOpString = map[int32]string{
+	AtomicOpAdd:               "atomic_add",
+	AtomicOpAdd | AtomicOpFetch: "atomic_fetch_add",
+	AtomicOpOr:                "atomic_or",
+	AtomicOpOr | AtomicOpFetch:  "atomic_fetch_or",
+	AtomicOpAnd:               "atomic_and",
+	AtomicOpAnd | AtomicOpFetch: "atomic_fetch_and",
+	AtomicOpXor:               "atomic_xor",
+	AtomicOpXor | AtomicOpFetch: "atomic_fetch_xor",
+	AtomicOpXchg:              "atomic_xchg",
+	AtomicOpCmpXchg:           "atomic_cmpxchg",
+}
+
 type OffsetFixer func(origInsn Insn) Insn
 
 // Maximum jump distance is math.MaxInt16, we need to start writing the
@@ -733,10 +954,10 @@ func (b *Block) writeTrampoline() {
 	b.trampolineIdx++
 	b.JumpNoTrampoline(endLabel)
 	for _, label := range labels {
-		b.LabelNextInsn(label)
+		b.labelNextInsnExact(label)
 		b.JumpNoTrampoline(label)
 	}
-	b.LabelNextInsn(endLabel)
+	b.labelNextInsnExact(endLabel)
 }
 
 func (b *Block) JumpNoTrampoline(endLabel string) {
@@ -745,6 +966,18 @@ func (b *Block) JumpNoTrampoline(endLabel string) {
 }
 
 func (b *Block) addInsnWithOffsetFixupNoTrampoline(insn Insn, targetLabel string) {
+	b.addInsnWithFixup(insn, targetLabel, false)
+}
+
+// addInsnWithImmFixup is addInsnWithOffsetFixupNoTrampoline's counterpart for BPF-to-BPF calls:
+// the resolved offset is written into the imm field instead of the offset field. It never goes
+// through maybeWriteTrampoline -- the imm field is 32 bits wide, so a call's target is never out
+// of range the way a jump's 16-bit offset can be.
+func (b *Block) addInsnWithImmFixup(insn Insn, targetLabel string) {
+	b.addInsnWithFixup(insn, targetLabel, true)
+}
+
+func (b *Block) addInsnWithFixup(insn Insn, targetLabel string, useImm bool) {
 	var insnLabel string
 	debug := log.IsLevelEnabled(log.DebugLevel)
 	if debug {
@@ -770,10 +1003,14 @@ func (b *Block) addInsnWithOffsetFixupNoTrampoline(insn Insn, targetLabel string
 	b.insns = append(b.insns, insn)
 	if targetLabel != "" {
 		if b.policyDebugEnabled {
-			b.insns[len(b.insns)-1].Annotation = fmt.Sprintf("goto %s", targetLabel)
+			if useImm {
+				b.insns[len(b.insns)-1].Annotation = fmt.Sprintf("call %s", targetLabel)
+			} else {
+				b.insns[len(b.insns)-1].Annotation = fmt.Sprintf("goto %s", targetLabel)
+			}
 		}
 		b.inUseJumpTargets.Add(targetLabel)
-		b.fixUps[targetLabel] = append(b.fixUps[targetLabel], fixUp{origInsnIdx: len(b.insns) - 1})
+		b.fixUps[targetLabel] = append(b.fixUps[targetLabel], fixUp{origInsnIdx: len(b.insns) - 1, useImm: useImm})
 	}
 	if insn.OpClass() == OpClassJump64 || insn.OpClass() == OpClassJump32 {
 		// Track number of jumps written, useful for estimating how complex
@@ -803,6 +1040,23 @@ func (b *Block) Assemble() (Insns, error) {
 		return nil, b.deferredErr
 	}
 
+	b.appendSubprograms()
+	if b.deferredErr != nil {
+		return nil, b.deferredErr
+	}
+
+	if err := b.foldConstantConditions(); err != nil {
+		return nil, err
+	}
+
+	if err := b.resolveBackwardOverflows(); err != nil {
+		return nil, err
+	}
+
+	if err := b.resolveLongJumpOverflows(); err != nil {
+		return nil, err
+	}
+
 	for label := range b.fixUps {
 		err := b.applyFixUps(label)
 		if err != nil {
@@ -832,6 +1086,13 @@ func (b *Block) applyFixUps(targetLabel string) error {
 		}
 		// Offset is relative to the next instruction since the PC is auto-incremented.
 		offset := labelIdx - f.origInsnIdx - 1
+		if f.useImm {
+			// BPF-to-BPF call: kernel ABI puts the relative offset in the 32-bit imm field, so
+			// there's no 16-bit range check, and calling the very next instruction is legal
+			// (unlike the same-instruction jump case below).
+			binary.LittleEndian.PutUint32(b.insns[f.origInsnIdx].Instruction[4:8], uint32(offset))
+			continue
+		}
 		if offset == -1 {
 			// This case is made more likely by the trampoline machinery
 			// since it's what we'd hit if a trampoline was generated but
@@ -848,6 +1109,12 @@ func (b *Block) applyFixUps(targetLabel string) error {
 }
 
 func (b *Block) LabelNextInsn(label string) {
+	b.labelNextInsnExact(b.resolveLabel(label))
+}
+
+// labelNextInsnExact is LabelNextInsn without label-prefix/exit-map resolution, for internal
+// callers (trampoline writing, subprogram layout) that already have a final, absolute label name.
+func (b *Block) labelNextInsnExact(label string) {
 	b.labelToInsnIdx[label] = len(b.insns)
 	b.insnIdxToLabels[len(b.insns)] = append(b.insnIdxToLabels[len(b.insns)], label)
 
@@ -916,3 +1183,12 @@ func (b *Block) SetTrampolineStride(s int) {
 		b.trampolineStride = s
 	}
 }
+
+// SetLongJumpsEnabled controls whether Assemble runs resolveLongJumpOverflows, which materialises a
+// jump whose outstanding fix-up doesn't fit in int16 as a LongJumpA (32-bit offset) instead of
+// leaving applyFixUps to fail. It does not affect trampoline writing, which SetTrampolinesEnabled
+// controls on its own. Leave disabled (the default) unless the target kernel is known to support
+// the gotol/gotol32 long-jump encoding.
+func (b *Block) SetLongJumpsEnabled(en bool) {
+	b.longJumpsEnabled = en
+}
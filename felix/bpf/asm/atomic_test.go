@@ -0,0 +1,71 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asm
+
+import "testing"
+
+// TestAtomicInstructions checks that each atomic helper emits the expected opcode/dst/src/offset
+// and packs the right sub-operation (optionally OR'd with AtomicOpFetch) into the imm field, per
+// the kernel's BPF_ATOMIC ABI.
+func TestAtomicInstructions(t *testing.T) {
+	fo := FieldOffset{Offset: 16}
+
+	cases := []struct {
+		name   string
+		build  func(b *Block)
+		opcode OpCode
+		imm    int32
+	}{
+		{"AtomicAdd32", func(b *Block) { b.AtomicAdd32(R6, R7, fo) }, Atomic32, AtomicOpAdd},
+		{"AtomicAdd64", func(b *Block) { b.AtomicAdd64(R6, R7, fo) }, Atomic64, AtomicOpAdd},
+		{"AtomicOr32", func(b *Block) { b.AtomicOr32(R6, R7, fo) }, Atomic32, AtomicOpOr},
+		{"AtomicAnd64", func(b *Block) { b.AtomicAnd64(R6, R7, fo) }, Atomic64, AtomicOpAnd},
+		{"AtomicXor32", func(b *Block) { b.AtomicXor32(R6, R7, fo) }, Atomic32, AtomicOpXor},
+		{"AtomicFetchAdd64", func(b *Block) { b.AtomicFetchAdd64(R6, R7, fo) }, Atomic64, AtomicOpAdd | AtomicOpFetch},
+		{"AtomicFetchOr32", func(b *Block) { b.AtomicFetchOr32(R6, R7, fo) }, Atomic32, AtomicOpOr | AtomicOpFetch},
+		{"AtomicXchg64", func(b *Block) { b.AtomicXchg64(R6, R7, fo) }, Atomic64, AtomicOpXchg},
+		{"AtomicCmpXchg32", func(b *Block) { b.AtomicCmpXchg32(R6, R7, fo) }, Atomic32, AtomicOpCmpXchg},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			b := NewBlock(false)
+			c.build(b)
+			insns, err := b.Assemble()
+			if err != nil {
+				t.Fatalf("Assemble() returned an error: %v", err)
+			}
+			if len(insns) != 1 {
+				t.Fatalf("expected exactly one instruction, got %d", len(insns))
+			}
+			insn := insns[0]
+			if insn.OpCode() != c.opcode {
+				t.Errorf("OpCode() = %v, want %v", insn.OpCode(), c.opcode)
+			}
+			if insn.Dst() != R6 {
+				t.Errorf("Dst() = %v, want R6", insn.Dst())
+			}
+			if insn.Src() != R7 {
+				t.Errorf("Src() = %v, want R7", insn.Src())
+			}
+			if insn.Off() != fo.Offset {
+				t.Errorf("Off() = %d, want %d", insn.Off(), fo.Offset)
+			}
+			if insn.Imm() != c.imm {
+				t.Errorf("Imm() = %#x, want %#x", insn.Imm(), c.imm)
+			}
+		})
+	}
+}
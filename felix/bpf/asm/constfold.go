@@ -0,0 +1,284 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asm
+
+import "fmt"
+
+// regConst is the per-register abstract value tracked by foldConstantConditions's dataflow pass:
+// a register either holds a known compile-time constant or (the common case, and the starting
+// state for every register) isn't tracked, in which case no branch reading it can be folded.
+type regConst struct {
+	known bool
+	value int64
+}
+
+// foldConstantConditions runs from Assemble, before resolveBackwardOverflows and before any
+// fix-up is baked into final instruction bytes: a single forward scan over b.insns tracking, per
+// register, whether its value is a known compile-time constant at each point -- set by
+// MovImm64/MovImm32/LoadImm64, folded through AddImm64/AndImm64/OrImm64/ShiftLImm64/ShiftRImm64
+// when the input is itself known, and invalidated by anything else that writes the register. That
+// state resolves any ALUSrcImm comparison jump whose outcome it makes statically decidable:
+// "always taken" is rewritten to JumpA in place, "never taken" is deleted outright.
+//
+// This is aimed at patterns the policy builder emits like:
+//
+//	MOV r1, <proto>; JumpNEImm r1, 6, skip
+//
+// where <proto> is a compile-time constant for a particular rule. Folding these before fix-ups
+// run shrinks what the verifier sees and lets resolveBackwardOverflows skip trampolines for any
+// backward jump whose only remaining reference was a branch this pass just removed.
+func (b *Block) foldConstantConditions() error {
+	regs := make(map[Reg]regConst, 10)
+	for i := 0; i < len(b.insns); i++ {
+		insn := b.insns[i]
+		if len(b.insnIdxToLabels[i]) > 0 {
+			// A labelled instruction is a jump target: another path may reach it with other values.
+			regs = make(map[Reg]regConst, 10)
+		}
+
+		if taken, ok := evalBranch(insn, regs); ok {
+			removed, err := b.foldBranch(i, insn, taken)
+			if err != nil {
+				return err
+			}
+			if removed {
+				i-- // re-examine whatever just slid into i.
+			}
+			continue
+		}
+
+		if insn.OpCode() == LoadImm64 && i+1 < len(b.insns) {
+			regs[insn.Dst()] = regConst{
+				known: true,
+				value: int64(uint32(insn.Imm())) | int64(b.insns[i+1].Imm())<<32,
+			}
+			i++ // Pt2 carries no register write of its own to track.
+			continue
+		}
+		updateRegConst(regs, insn)
+	}
+	return nil
+}
+
+// updateRegConst folds insn's effect on regs: set to a known constant for the handful of opcodes
+// foldConstantConditions tracks (including the immediate ALU ops, when their input is itself
+// known), or invalidated -- dropped from regs -- for anything else that writes a register,
+// including a helper or subprogram call, which per the BPF calling convention may clobber any of
+// R0 (return value) through R5 (arguments).
+func updateRegConst(regs map[Reg]regConst, insn Insn) {
+	switch insn.OpCode() {
+	case MovImm64:
+		regs[insn.Dst()] = regConst{known: true, value: int64(insn.Imm())}
+		return
+	case MovImm32:
+		regs[insn.Dst()] = regConst{known: true, value: int64(uint32(insn.Imm()))}
+		return
+	case AddImm64, AndImm64, OrImm64, ShiftLImm64, ShiftRImm64:
+		if cur, ok := regs[insn.Dst()]; ok && cur.known {
+			regs[insn.Dst()] = regConst{known: true, value: applyImmALU(insn.OpCode(), cur.value, insn.Imm())}
+			return
+		}
+		delete(regs, insn.Dst())
+		return
+	case Call:
+		for _, r := range [...]Reg{R0, R1, R2, R3, R4, R5} {
+			delete(regs, r)
+		}
+		return
+	case Atomic32, Atomic64:
+		// Fetching atomics write the old value back into src; cmpxchg writes it into R0 instead.
+		if insn.Imm() == AtomicOpCmpXchg {
+			delete(regs, R0)
+		} else if insn.Imm()&AtomicOpFetch != 0 {
+			delete(regs, insn.Src())
+		}
+		return
+	}
+
+	switch insn.OpClass() {
+	case OpClassALU64, OpClassALU32, OpClassLoadReg:
+		delete(regs, insn.Dst())
+	}
+}
+
+// applyImmALU evaluates one of the immediate ALU ops updateRegConst folds through, against a
+// known register value.
+func applyImmALU(op OpCode, cur int64, imm int32) int64 {
+	switch op {
+	case AddImm64:
+		return cur + int64(imm)
+	case AndImm64:
+		return cur & int64(imm)
+	case OrImm64:
+		return cur | int64(imm)
+	case ShiftLImm64:
+		return cur << uint(imm)
+	case ShiftRImm64:
+		return int64(uint64(cur) >> uint(imm))
+	default:
+		panic(fmt.Sprintf("applyImmALU: unexpected opcode %v", op))
+	}
+}
+
+// evalBranch reports whether insn is an ALUSrcImm comparison jump whose outcome is statically
+// decidable given regs, i.e. its compared register holds a known constant. ok is false for any
+// other instruction, including a comparison against an unknown register or a register-vs-register
+// comparison -- not worth tracking two constants for a pattern the policy builder doesn't emit.
+func evalBranch(insn Insn, regs map[Reg]regConst) (taken, ok bool) {
+	class := insn.OpClass()
+	if class != OpClassJump64 && class != OpClassJump32 {
+		return false, false
+	}
+	if insn.OpCode()&ALUSrcReg != 0 {
+		return false, false // reg-vs-reg; not tracked.
+	}
+
+	cur, known := regs[insn.Dst()]
+	if !known || !cur.known {
+		return false, false
+	}
+
+	var lhsU, rhsU uint64
+	var lhsS, rhsS int64
+	if class == OpClassJump32 {
+		lhsU, rhsU = uint64(uint32(cur.value)), uint64(uint32(insn.Imm()))
+		lhsS, rhsS = int64(int32(uint32(cur.value))), int64(insn.Imm())
+	} else {
+		lhsU, rhsU = uint64(cur.value), uint64(int64(insn.Imm()))
+		lhsS, rhsS = cur.value, int64(insn.Imm())
+	}
+
+	switch jumpKind(insn.OpCode()) {
+	case JumpOpEq:
+		return lhsU == rhsU, true
+	case JumpOpNE:
+		return lhsU != rhsU, true
+	case JumpOpGT:
+		return lhsU > rhsU, true
+	case JumpOpGE:
+		return lhsU >= rhsU, true
+	case JumpOpLT:
+		return lhsU < rhsU, true
+	case JumpOpLE:
+		return lhsU <= rhsU, true
+	case JumpOpSGT:
+		return lhsS > rhsS, true
+	case JumpOpSGE:
+		return lhsS >= rhsS, true
+	case JumpOpSLT:
+		return lhsS < rhsS, true
+	case JumpOpSLE:
+		return lhsS <= rhsS, true
+	case JumpOpSet:
+		return lhsU&rhsU != 0, true
+	default:
+		return false, false // JumpOpA, JumpOpCall, JumpOpExit: not comparisons.
+	}
+}
+
+// foldBranch applies evalBranch's verdict for the comparison jump at index i: "always taken"
+// (taken == true) is rewritten to a plain JumpA in place, keeping its existing fix-up (if any)
+// untouched since the target label hasn't changed; "never taken" drops its fix-up, if the target
+// hasn't already been eagerly resolved, and deletes the instruction outright.
+func (b *Block) foldBranch(i int, insn Insn, taken bool) (removed bool, err error) {
+	if taken {
+		rewritten := MakeInsn(JumpA, 0, 0, insn.Off(), 0)
+		rewritten.Annotation = insn.Annotation
+		b.insns[i] = rewritten
+		return false, nil
+	}
+
+	if label, ok := b.fixUpLabelFor(i); ok {
+		b.removeFixUp(label, i)
+	}
+	if err := b.removeInsn(i); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// fixUpLabelFor returns the label an as-yet-unresolved fix-up at origIdx targets, if any. A
+// forward branch whose target resolved before this pass ran (eagerly, via labelNextInsnExact) has
+// no entry here and needs none: the fix-up is already gone, along with the only instruction that
+// referenced it.
+func (b *Block) fixUpLabelFor(origIdx int) (string, bool) {
+	for label, fixups := range b.fixUps {
+		for _, fu := range fixups {
+			if fu.origInsnIdx == origIdx {
+				return label, true
+			}
+		}
+	}
+	return "", false
+}
+
+// removeInsn deletes the instruction at idx from the stream, shifting every later instruction
+// (and the bookkeeping maps that reference instruction indices by position) back by one. Any
+// label that pointed at idx is reattached to whatever now occupies that slot instead of being
+// dropped: other jumps may still target it.
+func (b *Block) removeInsn(idx int) error {
+	if idx < 0 || idx >= len(b.insns) {
+		return fmt.Errorf("removeInsn: index %d out of range (len=%d)", idx, len(b.insns))
+	}
+
+	if labels, ok := b.insnIdxToLabels[idx]; ok {
+		b.insnIdxToLabels[idx+1] = append(labels, b.insnIdxToLabels[idx+1]...)
+		delete(b.insnIdxToLabels, idx)
+	}
+	if comments, ok := b.insnIdxToComments[idx]; ok {
+		b.insnIdxToComments[idx+1] = append(comments, b.insnIdxToComments[idx+1]...)
+		delete(b.insnIdxToComments, idx)
+	}
+
+	b.insns = append(b.insns[:idx], b.insns[idx+1:]...)
+
+	shiftIndexMap := func(m map[int][]string) {
+		updated := make(map[int][]string, len(m))
+		for i, v := range m {
+			if i > idx {
+				updated[i-1] = v
+			} else {
+				updated[i] = v
+			}
+		}
+		for k := range m {
+			delete(m, k)
+		}
+		for k, v := range updated {
+			m[k] = v
+		}
+	}
+	shiftIndexMap(b.insnIdxToLabels)
+	shiftIndexMap(b.insnIdxToComments)
+
+	for label, labelIdx := range b.labelToInsnIdx {
+		switch {
+		case labelIdx == idx:
+			b.labelToInsnIdx[label] = idx // whatever now occupies idx, after the shift above.
+		case labelIdx > idx:
+			b.labelToInsnIdx[label] = labelIdx - 1
+		}
+	}
+	for label, fixups := range b.fixUps {
+		for i := range fixups {
+			if fixups[i].origInsnIdx > idx {
+				fixups[i].origInsnIdx--
+			}
+		}
+		b.fixUps[label] = fixups
+	}
+
+	return nil
+}
@@ -0,0 +1,155 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asm
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// DispatchCase is one value->label mapping for BinarySearchDispatch. Values must be unique.
+type DispatchCase struct {
+	Value uint32
+	Label string
+}
+
+// dispatchRange is a run of DispatchCases, after collapsing consecutive values that target the
+// same label into a single span.
+type dispatchRange struct {
+	lo, hi uint32
+	label  string
+}
+
+// BinarySearchDispatch emits a balanced binary decision tree that jumps to the Label of the
+// DispatchCase matching srcReg, or to defaultLabel if none match. It's modeled on the gVisor
+// seccomp compiler's syscall dispatcher: cases are sorted by value, contiguous runs targeting the
+// same label collapse into a single ">= lo && <= hi" pair rather than one equality check per
+// value, and the remaining ranges are laid out as a balanced tree so a match costs O(log n)
+// comparisons instead of the O(n) a linear chain of equality checks would. This is aimed at the
+// long linear chains of port/protocol/IPset-id comparisons policy programs emit today, which cost
+// the verifier far more than the tree form does.
+//
+// At each split, only the upper half costs a label and a jump -- the lower half falls straight
+// through -- so the tree's hot path has far fewer jumps per rule than the equivalent linear chain,
+// and (since every comparison still goes through the usual JumpXXXImm64/JumpXXX64 builder methods)
+// it still interacts correctly with the trampoline machinery if trampolineStride is reached
+// partway through the tree.
+//
+// scratchReg is clobbered and must not be srcReg: case values at or above 1<<31 don't fit the
+// sign-extending 32-bit immediate the Imm jump variants take, so those particular comparisons load
+// the exact value into scratchReg with LoadImm64 and compare register-to-register instead.
+func (b *Block) BinarySearchDispatch(srcReg Reg, scratchReg Reg, cases []DispatchCase, defaultLabel string) {
+	b.emitDispatchRanges(srcReg, scratchReg, collapseDispatchCases(cases))
+	b.Jump(defaultLabel)
+}
+
+func collapseDispatchCases(cases []DispatchCase) []dispatchRange {
+	if len(cases) == 0 {
+		return nil
+	}
+	sorted := make([]DispatchCase, len(cases))
+	copy(sorted, cases)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Value < sorted[j].Value })
+
+	ranges := make([]dispatchRange, 0, len(sorted))
+	cur := dispatchRange{lo: sorted[0].Value, hi: sorted[0].Value, label: sorted[0].Label}
+	for _, c := range sorted[1:] {
+		if c.Label == cur.label && c.Value == cur.hi+1 {
+			cur.hi = c.Value
+			continue
+		}
+		ranges = append(ranges, cur)
+		cur = dispatchRange{lo: c.Value, hi: c.Value, label: c.Label}
+	}
+	ranges = append(ranges, cur)
+	return ranges
+}
+
+// emitDispatchRanges emits the tree for ranges, which must be sorted and non-overlapping. It
+// always falls through to the code that should run when srcReg matches none of ranges.
+func (b *Block) emitDispatchRanges(srcReg, scratchReg Reg, ranges []dispatchRange) {
+	if len(ranges) == 0 {
+		return
+	}
+	if len(ranges) == 1 {
+		b.emitDispatchLeaf(srcReg, scratchReg, ranges[0])
+		return
+	}
+
+	mid := len(ranges) / 2
+	left, right := ranges[:mid], ranges[mid:]
+	rightLabel := b.newDispatchLabel()
+	b.dispatchJumpGE(srcReg, scratchReg, right[0].lo, rightLabel)
+	b.emitDispatchRanges(srcReg, scratchReg, left)
+	b.LabelNextInsn(rightLabel)
+	b.emitDispatchRanges(srcReg, scratchReg, right)
+}
+
+func (b *Block) emitDispatchLeaf(srcReg, scratchReg Reg, r dispatchRange) {
+	if r.lo == r.hi {
+		b.dispatchJumpEQ(srcReg, scratchReg, r.lo, r.label)
+		return
+	}
+	skipLabel := b.newDispatchLabel()
+	b.dispatchJumpLT(srcReg, scratchReg, r.lo, skipLabel)
+	b.dispatchJumpLE(srcReg, scratchReg, r.hi, r.label)
+	b.LabelNextInsn(skipLabel)
+}
+
+func (b *Block) newDispatchLabel() string {
+	b.dispatchLabelIdx++
+	return fmt.Sprintf("__bsearch-dispatch$%d", b.dispatchLabelIdx)
+}
+
+// dispatchJumpEQ, dispatchJumpLT, dispatchJumpLE and dispatchJumpGE each emit a jump comparing
+// srcReg against value, taking the sign-extending Imm builder when value fits and falling back to
+// an exact LoadImm64 into scratchReg plus a register-to-register compare when it doesn't.
+
+func (b *Block) dispatchJumpEQ(srcReg, scratchReg Reg, value uint32, label string) {
+	if value <= math.MaxInt32 {
+		b.JumpEqImm64(srcReg, int32(value), label)
+		return
+	}
+	b.LoadImm64(scratchReg, int64(value))
+	b.JumpEq64(srcReg, scratchReg, label)
+}
+
+func (b *Block) dispatchJumpLT(srcReg, scratchReg Reg, value uint32, label string) {
+	if value <= math.MaxInt32 {
+		b.JumpLTImm64(srcReg, int32(value), label)
+		return
+	}
+	b.LoadImm64(scratchReg, int64(value))
+	b.JumpLT64(srcReg, scratchReg, label)
+}
+
+func (b *Block) dispatchJumpLE(srcReg, scratchReg Reg, value uint32, label string) {
+	if value <= math.MaxInt32 {
+		b.JumpLEImm64(srcReg, int32(value), label)
+		return
+	}
+	b.LoadImm64(scratchReg, int64(value))
+	b.JumpLE64(srcReg, scratchReg, label)
+}
+
+func (b *Block) dispatchJumpGE(srcReg, scratchReg Reg, value uint32, label string) {
+	if value <= math.MaxInt32 {
+		b.JumpGEImm64(srcReg, int32(value), label)
+		return
+	}
+	b.LoadImm64(scratchReg, int64(value))
+	b.JumpGE64(srcReg, scratchReg, label)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asm
+
+import "fmt"
+
+// Fragment is a named, reusable chunk of instructions with one or more symbolic exits (e.g. "hit",
+// "miss", "malformed") instead of a single return point. It lets large pieces of logic -- parsing
+// IPv4+TCP, looking up an IP set, applying a connection-state check -- be written once and inlined
+// at every call site that needs them, the way neo-go's compiler inlines a function body at each of
+// its call sites rather than emitting a real call.
+type Fragment struct {
+	name  string
+	exits []string
+	build func(*Block)
+}
+
+// NewFragment registers a fragment: exits lists the symbolic labels build may jump to instead of
+// falling off the end, and build emits the fragment's body using the normal Block builder methods.
+// build runs once per InlineFragment call site (not once globally) -- every inlining gets its own
+// fresh copy of the fragment's instructions.
+func NewFragment(name string, exits []string, build func(*Block)) *Fragment {
+	return &Fragment{name: name, exits: append([]string(nil), exits...), build: build}
+}
+
+// InlineFragment splices a fresh copy of frag's instructions into b: every label frag's build
+// defines internally is rewritten with a prefix unique to this call site, so the same Fragment can
+// be inlined any number of times in the same Block without its labels colliding, and every jump to
+// one of frag's exits is redirected to exitMap's target for that exit instead. exitMap must have an
+// entry for every name in frag's exits.
+//
+// Every branch InlineFragment produces is an ordinary labelled jump generated via the usual
+// Jump/LabelNextInsn machinery, so the trampoline logic and applyFixUps need no special-casing to
+// handle it. Fragments compose: build may itself call InlineFragment on another Fragment, and the
+// inner call's renaming doesn't leak into the outer one (or vice versa) because the label-prefix
+// and exit-map state it uses is saved and restored around the call. When an outer fragment's
+// exitMap for that inner call needs to name one of the outer fragment's own local labels, it must
+// pass b.ResolveLabel("that-label") rather than the bare name, since exit-map targets are used as
+// final, absolute label names and aren't themselves prefixed.
+func (b *Block) InlineFragment(frag *Fragment, exitMap map[string]string) {
+	for _, exit := range frag.exits {
+		if _, ok := exitMap[exit]; !ok {
+			if b.deferredErr == nil {
+				b.deferredErr = fmt.Errorf("fragment %q: InlineFragment call is missing a target for exit %q", frag.name, exit)
+			}
+			return
+		}
+	}
+
+	b.fragmentInlineIdx++
+	savedPrefix, savedExitMap := b.labelPrefix, b.exitMap
+	b.labelPrefix = fmt.Sprintf("%s$%s#%d$", savedPrefix, frag.name, b.fragmentInlineIdx)
+	b.exitMap = exitMap
+
+	frag.build(b)
+
+	b.labelPrefix, b.exitMap = savedPrefix, savedExitMap
+}
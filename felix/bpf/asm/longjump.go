@@ -0,0 +1,140 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asm
+
+import (
+	"fmt"
+	"math"
+)
+
+// invertedJumpKind maps each conditional jump's JumpOpXXX bits to its logical negation's. JumpOpA,
+// JumpOpCall, JumpOpExit and JumpOpSet have no entry: the first three aren't comparisons to negate,
+// and JSET (src&dst != 0) has no single BPF comparison that expresses its complement.
+var invertedJumpKind = map[OpCode]OpCode{
+	JumpOpEq:  JumpOpNE,
+	JumpOpNE:  JumpOpEq,
+	JumpOpGT:  JumpOpLE,
+	JumpOpLE:  JumpOpGT,
+	JumpOpGE:  JumpOpLT,
+	JumpOpLT:  JumpOpGE,
+	JumpOpSGT: JumpOpSLE,
+	JumpOpSLE: JumpOpSGT,
+	JumpOpSGE: JumpOpSLT,
+	JumpOpSLT: JumpOpSGE,
+}
+
+// invertedJumpOp returns the opcode for the logical negation of insn's comparison, preserving its
+// class (Jump64 vs Jump32) and operand-source bit, and false if insn's comparison has no such
+// single-instruction inverse.
+func invertedJumpOp(insn Insn) (OpCode, bool) {
+	invKind, ok := invertedJumpKind[jumpKind(insn.OpCode())]
+	if !ok {
+		return 0, false
+	}
+	return (insn.OpCode() &^ OpCode(0b1111_0000)) | invKind, true
+}
+
+// resolveLongJumpOverflows runs from Assemble, after resolveBackwardOverflows and before any
+// fix-up is baked into final instruction bytes by applyFixUps. While SetLongJumpsEnabled is on, it
+// repeatedly scans outstanding fix-ups for a forward branch whose resolved offset would overflow
+// the 16-bit jump range and, for each one, materialises a LongJumpA in its place.
+//
+// This has to run as its own fixed-point pass entirely before applyFixUps bakes anything: turning
+// a conditional jump into a long jump splices in an extra instruction, shifting every later
+// instruction index, and an offset already baked into raw instruction bytes earlier in the same
+// Assemble() run has no way to be revisited once it's been written -- unlike an outstanding
+// fix-up, which is always resolved against the current, post-splice positions. Running entirely
+// before any baking, and re-deriving every position from the (possibly just-shifted) bookkeeping
+// maps on each iteration, is what keeps this safe regardless of the order Assemble's label loop
+// happens to visit labels in.
+func (b *Block) resolveLongJumpOverflows() error {
+	if !b.longJumpsEnabled {
+		return nil
+	}
+	for {
+		origIdx, label, ok := b.findLongJumpOverflow()
+		if !ok {
+			return nil
+		}
+		if err := b.materializeLongJump(origIdx, label); err != nil {
+			return err
+		}
+	}
+}
+
+// findLongJumpOverflow returns the first outstanding fix-up whose resolved offset would exceed the
+// 16-bit jump range, or ok=false if there are none left. Fix-ups that resolve into the 32-bit Imm
+// field (useImm -- BPF-to-BPF calls, and any LongJumpA already materialised by a previous
+// iteration) are never a match: neither has a 16-bit range to overflow.
+func (b *Block) findLongJumpOverflow() (origIdx int, label string, ok bool) {
+	for l, fixups := range b.fixUps {
+		labelIdx, found := b.labelToInsnIdx[l]
+		if !found {
+			continue
+		}
+		for _, fu := range fixups {
+			if fu.useImm {
+				continue
+			}
+			offset := labelIdx - fu.origInsnIdx - 1
+			if offset <= math.MaxInt16 && offset >= math.MinInt16 {
+				continue
+			}
+			return fu.origInsnIdx, l, true
+		}
+	}
+	return 0, "", false
+}
+
+// materializeLongJump rewrites the branch at origIdx -- whose resolved offset to targetLabel has
+// just been found not to fit in the 16-bit jump range -- into a form that does, using LongJumpA's
+// 32-bit-offset encoding:
+//
+//   - A plain JumpA is simply re-encoded to LongJumpA in place; nothing else in the instruction
+//     stream moves.
+//   - Any other branch (a conditional jump) is split into two instructions: itself, flipped to the
+//     logical negation of its comparison and repointed two instructions further on (i.e. past the
+//     long jump about to be spliced in right after it), followed by a LongJumpA carrying the long
+//     offset to targetLabel. So `Jcc L; X` becomes `Jcc_inverted +1; JA_imm32 L; X` -- if the
+//     original condition held, the inverted branch isn't taken and execution falls into the long
+//     jump to L; if it didn't, the inverted branch skips the long jump straight to X.
+//
+// Either way, the new LongJumpA's target isn't resolved here: it's registered as an ordinary
+// (useImm) fix-up instead, so applyFixUps bakes its final offset in the usual place, after every
+// other long jump this pass still has to splice has had its chance to shift things further.
+func (b *Block) materializeLongJump(origIdx int, targetLabel string) error {
+	insn := b.insns[origIdx]
+	b.removeFixUp(targetLabel, origIdx)
+
+	if isUnconditionalJump(insn) {
+		b.insns[origIdx] = MakeInsn(LongJumpA, 0, 0, 0, 0)
+		b.fixUps[targetLabel] = append(b.fixUps[targetLabel], fixUp{origInsnIdx: origIdx, useImm: true})
+		return nil
+	}
+
+	inverted, ok := invertedJumpOp(insn)
+	if !ok {
+		return fmt.Errorf("jump at instruction %d to label %q exceeds the 16-bit jump range and has "+
+			"no inverse to convert it to a long jump (opcode %v)", origIdx, targetLabel, insn.OpCode())
+	}
+	b.insns[origIdx] = MakeInsn(inverted, insn.Dst(), insn.Src(), 1, insn.Imm())
+
+	longJumpIdx := origIdx + 1
+	if err := b.insertInsns(longJumpIdx, Insns{MakeInsn(LongJumpA, 0, 0, 0, 0)}); err != nil {
+		return err
+	}
+	b.fixUps[targetLabel] = append(b.fixUps[targetLabel], fixUp{origInsnIdx: longJumpIdx, useImm: true})
+	return nil
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asm
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// PeepholeOptimize runs a post-assembly cleanup pass over insns (as returned by Block.Assemble,
+// text.Parse, or any other source of already-resolved instructions) and returns a fresh Insns with
+// three transforms applied, in order:
+//
+//  1. Jump threading: a branch or BPF-to-BPF call that targets a plain, unconditional JumpA is
+//     redirected straight to that JumpA's own target, chasing the whole chain. This is aimed at the
+//     relay jumps the trampoline machinery and InlineFragment's exit remapping tend to leave behind.
+//  2. Short-jump folding: a branch (conditional or not) whose target -- after threading -- is simply
+//     the next instruction can never change control flow, so it's replaced with a no-op.
+//  3. Dead-block removal: any instruction no longer reachable once (1) and (2) have run -- typically
+//     a relay jump that was the sole reason its block existed -- is deleted and every surviving
+//     branch's offset/imm is recomputed for the new, shorter instruction stream.
+//
+// insns itself is never mutated.
+func PeepholeOptimize(insns Insns) Insns {
+	out := make(Insns, len(insns))
+	copy(out, insns)
+
+	threadJumps(out)
+	foldShortJumps(out)
+	out = removeDeadInsns(out)
+
+	return out
+}
+
+// jumpKind extracts the jump-operation bits (JumpOpXXX) from a jump-class opcode.
+func jumpKind(op OpCode) OpCode {
+	return op & 0b1111_0000
+}
+
+// isUnconditionalJump reports whether insn is a bare JumpA or LongJumpA: the only kind of instruction the
+// peephole pass ever threads other branches through or folds away on its own.
+func isUnconditionalJump(insn Insn) bool {
+	class := insn.OpClass()
+	return (class == OpClassJump64 || class == OpClassJump32) && jumpKind(insn.OpCode()) == JumpOpA
+}
+
+// isBranch reports whether insn carries a jump target in its Off() field -- every jump-class
+// instruction except Call (target, if any, is in Imm()) and Exit (no target at all).
+func isBranch(insn Insn) bool {
+	class := insn.OpClass()
+	if class != OpClassJump64 && class != OpClassJump32 {
+		return false
+	}
+	kind := jumpKind(insn.OpCode())
+	return kind != JumpOpCall && kind != JumpOpExit
+}
+
+// isSubprogramCall reports whether insn is a BPF-to-BPF call (as emitted by CallSubprogram), whose
+// target is a relative instruction offset carried in Imm() rather than a helper ID.
+func isSubprogramCall(insn Insn) bool {
+	return insn.OpCode() == Call && insn.Src() == RPseudoCall
+}
+
+// isLongJumpA reports whether insn is a LongJumpA: like a plain JumpA, it's an unconditional jump
+// and so satisfies isUnconditionalJump/isBranch too, but -- being BPF_JMP32 -- its target lives in
+// the 32-bit Imm() field rather than the 16-bit Off() field every other branch class uses. Callers
+// that read or rewrite a branch's target must check this first and use Imm(), or they'll silently
+// operate on the wrong field.
+func isLongJumpA(insn Insn) bool {
+	return insn.OpCode() == LongJumpA
+}
+
+// fallsThrough reports whether execution can reach insn's successor by falling off the end of
+// insn, as opposed to only via an explicit jump to it. False only for JumpA and Exit; every other
+// opcode -- including Call, which always returns -- falls through.
+func fallsThrough(insn Insn) bool {
+	class := insn.OpClass()
+	if class != OpClassJump64 && class != OpClassJump32 {
+		return true
+	}
+	kind := jumpKind(insn.OpCode())
+	return kind != JumpOpA && kind != JumpOpExit
+}
+
+func (n *Insn) setOff(off int16) {
+	binary.LittleEndian.PutUint16(n.Instruction[2:4], uint16(off))
+}
+
+func (n *Insn) setImm(imm int32) {
+	binary.LittleEndian.PutUint32(n.Instruction[4:8], uint32(imm))
+}
+
+// threadJumps rewrites every branch's and subprogram call's target in place, redirecting it past
+// any chain of plain JumpA instructions it lands on to that chain's final destination. A branch
+// whose final destination is out of reach of its 16-bit offset is left as it is.
+func threadJumps(insns Insns) {
+	n := len(insns)
+	for i := range insns {
+		switch insn := insns[i]; {
+		case isLongJumpA(insn):
+			target := threadTarget(insns, i+1+int(insn.Imm()), n)
+			insns[i].setImm(int32(target - i - 1))
+		case isBranch(insn):
+			target := threadTarget(insns, i+1+int(insn.Off()), n)
+			if off := target - i - 1; off >= math.MinInt16 && off <= math.MaxInt16 {
+				insns[i].setOff(int16(off))
+			}
+		case isSubprogramCall(insn):
+			target := threadTarget(insns, i+1+int(insn.Imm()), n)
+			insns[i].setImm(int32(target - i - 1))
+		}
+	}
+}
+
+// threadTarget chases target forward through any chain of plain JumpA/LongJumpA instructions it
+// lands on, returning the chain's final, non-jump destination. A chain can revisit at most n
+// distinct indices before it would have to repeat one, so tracking seen indices bounds the walk
+// and leaves a cyclical chain (illegal input, not this pass's job to reject) pointing at the first
+// jump in the cycle instead of looping forever.
+func threadTarget(insns Insns, target, n int) int {
+	seen := make(map[int]bool, 4)
+	for target >= 0 && target < n && isUnconditionalJump(insns[target]) && !seen[target] {
+		seen[target] = true
+		insn := insns[target]
+		if isLongJumpA(insn) {
+			target = target + 1 + int(insn.Imm())
+		} else {
+			target = target + 1 + int(insn.Off())
+		}
+	}
+	return target
+}
+
+// foldShortJumps replaces any branch -- conditional or not -- whose target is the very next
+// instruction with a no-op: taken or not, such a branch always ends up in the same place, so
+// whatever condition it tests is irrelevant.
+func foldShortJumps(insns Insns) {
+	for i, insn := range insns {
+		if !isBranch(insn) {
+			continue
+		}
+		var target int
+		if isLongJumpA(insn) {
+			target = i + 1 + int(insn.Imm())
+		} else {
+			target = i + 1 + int(insn.Off())
+		}
+		if target == i+1 {
+			noOp := MakeInsn(Mov64, R0, R0, 0, 0)
+			noOp.Labels, noOp.Comments = insn.Labels, insn.Comments
+			insns[i] = noOp
+		}
+	}
+}
+
+// removeDeadInsns deletes every instruction unreachable from insns[0], then recomputes the
+// offset/imm of every surviving branch and subprogram call for their new positions. Removal is the
+// common follow-on to threadJumps: a relay block that's lost its last incoming jump becomes dead
+// straight-line code sitting behind an unconditional jump or exit.
+func removeDeadInsns(insns Insns) Insns {
+	n := len(insns)
+	if n == 0 {
+		return insns
+	}
+
+	targets := make([]int, n)
+	for i := range targets {
+		targets[i] = -1
+	}
+	reachable := make([]bool, n)
+	reachable[0] = true
+	for changed := true; changed; {
+		changed = false
+		for i, insn := range insns {
+			if !reachable[i] {
+				continue
+			}
+			var target int
+			switch {
+			case isLongJumpA(insn):
+				target = i + 1 + int(insn.Imm())
+			case isBranch(insn):
+				target = i + 1 + int(insn.Off())
+			case isSubprogramCall(insn):
+				target = i + 1 + int(insn.Imm())
+			default:
+				target = -1
+			}
+			targets[i] = target
+			if target >= 0 && target < n && !reachable[target] {
+				reachable[target] = true
+				changed = true
+			}
+
+			if fallsThrough(insn) && i+1 < n && !reachable[i+1] {
+				reachable[i+1] = true
+				changed = true
+			}
+		}
+	}
+
+	remap := make([]int, n)
+	out := make(Insns, 0, n)
+	for i, r := range reachable {
+		if !r {
+			remap[i] = -1
+			continue
+		}
+		remap[i] = len(out)
+		out = append(out, insns[i])
+	}
+
+	for oldIdx, newIdx := range remap {
+		if newIdx == -1 || targets[oldIdx] < 0 || targets[oldIdx] >= n {
+			continue
+		}
+		newTarget := remap[targets[oldIdx]]
+		insn := &out[newIdx]
+		if isSubprogramCall(*insn) || isLongJumpA(*insn) {
+			insn.setImm(int32(newTarget - newIdx - 1))
+		} else {
+			insn.setOff(int16(newTarget - newIdx - 1))
+		}
+	}
+
+	return out
+}
@@ -0,0 +1,493 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package text
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/projectcalico/calico/felix/bpf/asm"
+)
+
+var (
+	reLoad        = regexp.MustCompile(`^(\S+) = \*\((u\d+) \*\)\((\S+) ([+-]) (\d+)\)$`)
+	reStoreImm    = regexp.MustCompile(`^\*\((u\d+) \*\)\((\S+) ([+-]) (\d+)\) = (-?\d+)$`)
+	reStoreReg    = regexp.MustCompile(`^\*\((u\d+) \*\)\((\S+) ([+-]) (\d+)\) = (\S+)$`)
+	reAtomicCall  = regexp.MustCompile(`^lock \*\((u\d+) \*\)\((\S+) ([+-]) (\d+)\) = (atomic_\w+)\((\S+)\)$`)
+	reAtomicCompd = regexp.MustCompile(`^lock \*\((u\d+) \*\)\((\S+) ([+-]) (\d+)\) (\+=|-=|\|=|&=|\^=) (\S+)$`)
+	reMapFD       = regexp.MustCompile(`^(\S+) = map_fd\((-?\d+)\) ll$`)
+	reImm64       = regexp.MustCompile(`^(\S+) = (-?\d+) ll$`)
+	reEndian      = regexp.MustCompile(`^(\S+) = (be|le)(16|32|64) (\S+)$`)
+	reNegate      = regexp.MustCompile(`^(\S+) = -(\S+)$`)
+	reIf          = regexp.MustCompile(`^if (\S+) (==|!=|>=|<=|s>=|s<=|s>|s<|>|<|&) (\S+) goto (.+)$`)
+	reGoto        = regexp.MustCompile(`^goto (.+)$`)
+	reCall        = regexp.MustCompile(`^call (-?\d+)$`)
+	reALU         = regexp.MustCompile(`^(\S+) (\+=|-=|\*=|/=|\|=|&=|<<=|>>=|s>>=|%=|\^=|=) (\S+)$`)
+)
+
+// instrSpec is a parsed instruction, still carrying an unresolved jump target (if any) so that
+// forward references to labels defined later in the input can be resolved in a second pass.
+type instrSpec struct {
+	slot int // instruction index this spec occupies (its first slot, for 2-slot specs)
+
+	labels     []string
+	comments   []string
+	annotation string
+
+	// For most specs, build just fills in insn directly.
+	insn asm.Insn
+	// loadImm64Hi is set for a 2-slot LoadImm64; insn holds the first slot.
+	loadImm64Hi *asm.Insn
+
+	// For jump specs, offset is resolved from either an explicit "+N" or a label lookup.
+	isJump       bool
+	gotoExplicit bool
+	gotoOffset   int16
+	gotoLabel    string
+}
+
+// Parse parses the textual syntax documented on the package into Insns. It is the inverse of
+// Print for any input Print could have produced.
+func Parse(r io.Reader) (asm.Insns, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 4096), 1024*1024)
+
+	labels := map[string]int{}
+	var specs []instrSpec
+	var pendingLabels, pendingComments []string
+	slot := 0
+	lineNo := 0
+
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, ";") {
+			pendingComments = append(pendingComments, strings.TrimSpace(strings.TrimPrefix(line, ";")))
+			continue
+		}
+		if strings.HasSuffix(line, ":") && !strings.ContainsAny(line, " \t") {
+			name := strings.TrimSuffix(line, ":")
+			labels[name] = slot
+			pendingLabels = append(pendingLabels, name)
+			continue
+		}
+
+		annotation := ""
+		if idx := strings.Index(line, strings.TrimSpace(annotationSep)); idx >= 0 {
+			annotation = strings.TrimSpace(line[idx+len(strings.TrimSpace(annotationSep)):])
+			line = strings.TrimSpace(line[:idx])
+		}
+
+		spec, err := parseInstrLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		spec.slot = slot
+		spec.labels = pendingLabels
+		spec.comments = pendingComments
+		spec.annotation = annotation
+		pendingLabels, pendingComments = nil, nil
+
+		specs = append(specs, spec)
+		if spec.loadImm64Hi != nil {
+			slot += 2
+		} else {
+			slot++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(pendingLabels) > 0 || len(pendingComments) > 0 {
+		return nil, fmt.Errorf("trailing label/comment with no following instruction")
+	}
+
+	out := make(asm.Insns, 0, slot)
+	for _, spec := range specs {
+		insn := spec.insn
+		if spec.isJump {
+			off := spec.gotoOffset
+			if !spec.gotoExplicit {
+				targetSlot, ok := labels[spec.gotoLabel]
+				if !ok {
+					return nil, fmt.Errorf("undefined label %q", spec.gotoLabel)
+				}
+				off = int16(targetSlot - spec.slot - 1)
+			}
+			insn = asm.MakeInsn(insn.OpCode(), insn.Dst(), insn.Src(), off, insn.Imm())
+		}
+		insn.Labels = spec.labels
+		insn.Comments = spec.comments
+		insn.Annotation = spec.annotation
+		out = append(out, insn)
+		if spec.loadImm64Hi != nil {
+			out = append(out, *spec.loadImm64Hi)
+		}
+	}
+	return out, nil
+}
+
+func parseInstrLine(line string) (instrSpec, error) {
+	switch {
+	case line == "exit":
+		return instrSpec{insn: asm.MakeInsn(asm.Exit, 0, 0, 0, 0)}, nil
+	case reCall.MatchString(line):
+		m := reCall.FindStringSubmatch(line)
+		imm, err := strconv.ParseInt(m[1], 0, 32)
+		if err != nil {
+			return instrSpec{}, err
+		}
+		return instrSpec{insn: asm.MakeInsn(asm.Call, 0, 0, 0, int32(imm))}, nil
+	case reAtomicCall.MatchString(line):
+		return parseAtomicCall(reAtomicCall.FindStringSubmatch(line))
+	case reAtomicCompd.MatchString(line):
+		return parseAtomicCompound(reAtomicCompd.FindStringSubmatch(line))
+	case reLoad.MatchString(line):
+		return parseLoad(reLoad.FindStringSubmatch(line))
+	case reStoreImm.MatchString(line):
+		return parseStoreImm(reStoreImm.FindStringSubmatch(line))
+	case reStoreReg.MatchString(line):
+		return parseStoreReg(reStoreReg.FindStringSubmatch(line))
+	case reMapFD.MatchString(line):
+		return parseMapFD(reMapFD.FindStringSubmatch(line))
+	case reImm64.MatchString(line):
+		return parseImm64(reImm64.FindStringSubmatch(line))
+	case reEndian.MatchString(line):
+		return parseEndian(reEndian.FindStringSubmatch(line))
+	case reNegate.MatchString(line) && isReg(reNegate.FindStringSubmatch(line)[2]):
+		// Guarded on isReg because "dst = -5" (a negative MovImm64) also matches this
+		// pattern syntactically; negate is only the "dst = -dst" register form.
+		return parseNegate(reNegate.FindStringSubmatch(line))
+	case reIf.MatchString(line):
+		return parseIf(reIf.FindStringSubmatch(line))
+	case reGoto.MatchString(line):
+		return parseGoto(reGoto.FindStringSubmatch(line))
+	case reALU.MatchString(line):
+		return parseALU(reALU.FindStringSubmatch(line))
+	}
+	return instrSpec{}, fmt.Errorf("unrecognised instruction: %q", line)
+}
+
+func parseOffset(sign, digits string) (int16, error) {
+	n, err := strconv.ParseInt(digits, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	if sign == "-" {
+		n = -n
+	}
+	return int16(n), nil
+}
+
+func parseLoad(m []string) (instrSpec, error) {
+	dst, _, err := parseReg(m[1])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	size, err := sizeMask(m[2])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	ptr, _, err := parseReg(m[3])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	off, err := parseOffset(m[4], m[5])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	return instrSpec{insn: asm.MakeInsn(asm.OpClassLoadReg|asm.MemOpModeMem|size, dst, ptr, off, 0)}, nil
+}
+
+func parseStoreImm(m []string) (instrSpec, error) {
+	size, err := sizeMask(m[1])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	dst, _, err := parseReg(m[2])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	off, err := parseOffset(m[3], m[4])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	imm, err := strconv.ParseInt(m[5], 0, 32)
+	if err != nil {
+		return instrSpec{}, err
+	}
+	return instrSpec{insn: asm.MakeInsn(asm.OpClassStoreImm|asm.MemOpModeImm|size, dst, 0, off, int32(imm))}, nil
+}
+
+func parseStoreReg(m []string) (instrSpec, error) {
+	size, err := sizeMask(m[1])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	dst, _, err := parseReg(m[2])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	off, err := parseOffset(m[3], m[4])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	src, _, err := parseReg(m[5])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	return instrSpec{insn: asm.MakeInsn(asm.OpClassStoreReg|asm.MemOpModeMem|size, dst, src, off, 0)}, nil
+}
+
+func parseAtomicCall(m []string) (instrSpec, error) {
+	size, err := sizeMask(m[1])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	dst, _, err := parseReg(m[2])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	off, err := parseOffset(m[3], m[4])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	subOp, ok := atomicSubOpForCallName(m[5])
+	if !ok {
+		return instrSpec{}, fmt.Errorf("unrecognised atomic op %q", m[5])
+	}
+	src, _, err := parseReg(m[6])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	return instrSpec{insn: asm.MakeInsn(asm.OpClassStoreReg|asm.MemOpModeAtomic|size, dst, src, off, subOp)}, nil
+}
+
+func parseAtomicCompound(m []string) (instrSpec, error) {
+	size, err := sizeMask(m[1])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	dst, _, err := parseReg(m[2])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	off, err := parseOffset(m[3], m[4])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	subOp, ok := atomicSubOpForCompoundSym(m[5])
+	if !ok {
+		return instrSpec{}, fmt.Errorf("unrecognised atomic compound operator %q", m[5])
+	}
+	src, _, err := parseReg(m[6])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	return instrSpec{insn: asm.MakeInsn(asm.OpClassStoreReg|asm.MemOpModeAtomic|size, dst, src, off, subOp)}, nil
+}
+
+func parseMapFD(m []string) (instrSpec, error) {
+	dst, _, err := parseReg(m[1])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	fd, err := strconv.ParseInt(m[2], 0, 64)
+	if err != nil {
+		return instrSpec{}, err
+	}
+	lo := asm.MakeInsn(asm.LoadImm64, dst, asm.RPseudoMapFD, 0, int32(fd))
+	hi := asm.MakeInsn(asm.LoadImm64Pt2, 0, 0, 0, 0)
+	return instrSpec{insn: lo, loadImm64Hi: &hi}, nil
+}
+
+func parseImm64(m []string) (instrSpec, error) {
+	dst, _, err := parseReg(m[1])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	val, err := strconv.ParseInt(m[2], 0, 64)
+	if err != nil {
+		return instrSpec{}, err
+	}
+	lo := asm.MakeInsn(asm.LoadImm64, dst, 0, 0, int32(uint64(val)))
+	hi := asm.MakeInsn(asm.LoadImm64Pt2, 0, 0, 0, int32(uint64(val)>>32))
+	return instrSpec{insn: lo, loadImm64Hi: &hi}, nil
+}
+
+func parseEndian(m []string) (instrSpec, error) {
+	dst, wide, err := parseReg(m[1])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	if _, _, err := parseReg(m[4]); err != nil {
+		return instrSpec{}, err
+	}
+	size, err := strconv.Atoi(m[3])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	class := asm.OpCode(asm.OpClassALU32)
+	if wide {
+		class = asm.OpClassALU64
+	}
+	dir := asm.OpCode(asm.OpEndianToLE)
+	if m[2] == "be" {
+		dir = asm.OpEndianToBE
+	}
+	return instrSpec{insn: asm.MakeInsn(class|asm.ALUOpEndian|dir, dst, 0, 0, int32(size))}, nil
+}
+
+func parseNegate(m []string) (instrSpec, error) {
+	dst, wide, err := parseReg(m[1])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	src, _, err := parseReg(m[2])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	if src != dst {
+		return instrSpec{}, fmt.Errorf("negate requires dst and src to be the same register, got %s and %s", m[1], m[2])
+	}
+	class := asm.OpCode(asm.OpClassALU32)
+	if wide {
+		class = asm.OpClassALU64
+	}
+	// Negate64/Negate32 are defined with ALUSrcReg set even though the op is unary; match that
+	// exactly so the encoded opcode byte round-trips.
+	return instrSpec{insn: asm.MakeInsn(class|asm.ALUSrcReg|asm.ALUOpNegate, dst, 0, 0, 0)}, nil
+}
+
+func parseALU(m []string) (instrSpec, error) {
+	dst, wide, err := parseReg(m[1])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	aluOp, ok := aluOpForSym(m[2])
+	if !ok {
+		return instrSpec{}, fmt.Errorf("unrecognised ALU operator %q", m[2])
+	}
+	class := asm.OpCode(asm.OpClassALU32)
+	if wide {
+		class = asm.OpClassALU64
+	}
+	if isReg(m[3]) {
+		src, srcWide, err := parseReg(m[3])
+		if err != nil {
+			return instrSpec{}, err
+		}
+		if srcWide != wide {
+			return instrSpec{}, fmt.Errorf("mismatched register widths in %q", strings.Join(m, " "))
+		}
+		return instrSpec{insn: asm.MakeInsn(class|asm.ALUSrcReg|aluOp, dst, src, 0, 0)}, nil
+	}
+	imm, err := strconv.ParseInt(m[3], 0, 32)
+	if err != nil {
+		return instrSpec{}, fmt.Errorf("expected register or immediate, got %q", m[3])
+	}
+	return instrSpec{insn: asm.MakeInsn(class|asm.ALUSrcImm|aluOp, dst, 0, 0, int32(imm))}, nil
+}
+
+func parseIf(m []string) (instrSpec, error) {
+	dst, wide, err := parseReg(m[1])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	jumpOp, ok := jumpOpForSym(m[2])
+	if !ok {
+		return instrSpec{}, fmt.Errorf("unrecognised comparison operator %q", m[2])
+	}
+	class := asm.OpCode(asm.OpClassJump32)
+	if wide {
+		class = asm.OpClassJump64
+	}
+
+	var src asm.Reg
+	var imm int32
+	var srcMode asm.OpCode
+	if isReg(m[3]) {
+		r, srcWide, err := parseReg(m[3])
+		if err != nil {
+			return instrSpec{}, err
+		}
+		if srcWide != wide {
+			return instrSpec{}, fmt.Errorf("mismatched register widths in %q", strings.Join(m, " "))
+		}
+		src = r
+		srcMode = asm.ALUSrcReg
+	} else {
+		n, err := strconv.ParseInt(m[3], 0, 32)
+		if err != nil {
+			return instrSpec{}, err
+		}
+		imm = int32(n)
+		srcMode = asm.ALUSrcImm
+	}
+
+	spec := instrSpec{
+		insn:   asm.MakeInsn(class|srcMode|jumpOp, dst, src, 0, imm),
+		isJump: true,
+	}
+	target := m[4]
+	off, explicit, label, err := parseGotoTarget(target)
+	if err != nil {
+		return instrSpec{}, err
+	}
+	spec.gotoExplicit = explicit
+	spec.gotoOffset = off
+	spec.gotoLabel = label
+	return spec, nil
+}
+
+func parseGoto(m []string) (instrSpec, error) {
+	spec := instrSpec{
+		insn:   asm.MakeInsn(asm.JumpA, 0, 0, 0, 0),
+		isJump: true,
+	}
+	off, explicit, label, err := parseGotoTarget(m[1])
+	if err != nil {
+		return instrSpec{}, err
+	}
+	spec.gotoExplicit = explicit
+	spec.gotoOffset = off
+	spec.gotoLabel = label
+	return spec, nil
+}
+
+// parseGotoTarget parses the target of a "goto" -- either "+N[ label]" (an explicit offset, with
+// an optional trailing label hint that's ignored) or a bare "label" (a forward/backward reference
+// resolved against the label table once the whole input has been scanned).
+func parseGotoTarget(s string) (off int16, explicit bool, label string, err error) {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		return 0, false, "", fmt.Errorf("empty goto target")
+	}
+	if strings.HasPrefix(fields[0], "+") || strings.HasPrefix(fields[0], "-") {
+		n, err := strconv.ParseInt(fields[0], 10, 32)
+		if err != nil {
+			return 0, false, "", fmt.Errorf("bad goto offset %q: %w", fields[0], err)
+		}
+		return int16(n), true, "", nil
+	}
+	return 0, false, fields[0], nil
+}
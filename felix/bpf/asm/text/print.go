@@ -0,0 +1,201 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package text
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/projectcalico/calico/felix/bpf/asm"
+)
+
+// Print renders insns in the textual syntax documented on the package, one instruction per line,
+// with labels rendered as "name:" lines and comments as ";"-prefixed lines ahead of the
+// instruction they were attached to.
+func Print(insns asm.Insns) (string, error) {
+	var sb strings.Builder
+	for idx := 0; idx < len(insns); idx++ {
+		insn := insns[idx]
+		for _, label := range insn.Labels {
+			sb.WriteString(label)
+			sb.WriteString(":\n")
+		}
+		for _, comment := range insn.Comments {
+			if strings.Contains(comment, "\n") {
+				return "", fmt.Errorf("insn %d: multi-line comment not supported by text format", idx)
+			}
+			sb.WriteString("\t; ")
+			sb.WriteString(comment)
+			sb.WriteByte('\n')
+		}
+		if strings.Contains(insn.Annotation, "\n") {
+			return "", fmt.Errorf("insn %d: multi-line annotation not supported by text format", idx)
+		}
+
+		var body string
+		var err error
+		slots := 1
+		if insn.OpCode() == asm.LoadImm64 {
+			if idx+1 >= len(insns) {
+				return "", fmt.Errorf("insn %d: LoadImm64 missing second slot", idx)
+			}
+			body, err = printLoadImm64(insn, insns[idx+1])
+			slots = 2
+		} else {
+			body, err = printInsn(insn, idx, insns)
+		}
+		if err != nil {
+			return "", fmt.Errorf("insn %d: %w", idx, err)
+		}
+
+		sb.WriteByte('\t')
+		sb.WriteString(body)
+		if insn.Annotation != "" {
+			sb.WriteString(annotationSep)
+			sb.WriteString(insn.Annotation)
+		}
+		sb.WriteByte('\n')
+		idx += slots - 1
+	}
+	return sb.String(), nil
+}
+
+func printLoadImm64(lo, hi asm.Insn) (string, error) {
+	dst := regName(lo.Dst(), true)
+	if lo.Src() == asm.RPseudoMapFD {
+		return fmt.Sprintf("%s = map_fd(%d) ll", dst, uint32(lo.Imm())), nil
+	}
+	full := int64(uint32(lo.Imm())) | int64(uint32(hi.Imm()))<<32
+	return fmt.Sprintf("%s = %d ll", dst, full), nil
+}
+
+func printInsn(insn asm.Insn, idx int, all asm.Insns) (string, error) {
+	switch insn.OpClass() {
+	case asm.OpClassALU64, asm.OpClassALU32:
+		return printALU(insn)
+	case asm.OpClassJump64, asm.OpClassJump32:
+		return printJump(insn, idx, all)
+	case asm.OpClassLoadReg:
+		size, err := sizeName(insn.OpCode())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s = *(%s *)(%s %s)", regName(insn.Dst(), true), size, regName(insn.Src(), true), offsetExpr(insn.Off())), nil
+	case asm.OpClassStoreReg:
+		return printStoreRegOrAtomic(insn)
+	case asm.OpClassStoreImm:
+		size, err := sizeName(insn.OpCode())
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("*(%s *)(%s %s) = %d", size, regName(insn.Dst(), true), offsetExpr(insn.Off()), insn.Imm()), nil
+	}
+	return "", fmt.Errorf("unsupported opcode %#x for text format", uint8(insn.OpCode()))
+}
+
+func printALU(insn asm.Insn) (string, error) {
+	wide := insn.OpClass() == asm.OpClassALU64
+	dst := regName(insn.Dst(), wide)
+	aluOp := insn.OpCode() & 0b1111_0_000
+	srcIsReg := insn.OpCode()&asm.ALUSrcReg != 0
+
+	switch aluOp {
+	case asm.ALUOpNegate:
+		return fmt.Sprintf("%s = -%s", dst, dst), nil
+	case asm.ALUOpEndian:
+		dir := "le"
+		if insn.OpCode()&asm.OpEndianToBE != 0 {
+			dir = "be"
+		}
+		return fmt.Sprintf("%s = %s%d %s", dst, dir, insn.Imm(), dst), nil
+	}
+
+	sym, ok := aluSymForOp(aluOp)
+	if !ok {
+		return "", fmt.Errorf("unsupported ALU op %#x", uint8(aluOp))
+	}
+	if srcIsReg {
+		return fmt.Sprintf("%s %s %s", dst, sym, regName(insn.Src(), wide)), nil
+	}
+	return fmt.Sprintf("%s %s %d", dst, sym, insn.Imm()), nil
+}
+
+func printJump(insn asm.Insn, idx int, all asm.Insns) (string, error) {
+	wide := insn.OpClass() == asm.OpClassJump64
+	jumpOp := insn.OpCode() & 0b1111_0_000
+
+	switch jumpOp {
+	case asm.JumpOpCall:
+		if insn.Src() != 0 {
+			return "", fmt.Errorf("BPF-to-BPF call (src=%d) not supported by text format", insn.Src())
+		}
+		return fmt.Sprintf("call %d", insn.Imm()), nil
+	case asm.JumpOpExit:
+		return "exit", nil
+	case asm.JumpOpA:
+		if insn.OpCode() == asm.LongJumpA {
+			return "", fmt.Errorf("long jump (32-bit offset) not supported by text format")
+		}
+		return fmt.Sprintf("goto %s", printGotoTarget(insn, idx, all)), nil
+	}
+
+	sym, ok := jumpSymForOp(jumpOp)
+	if !ok {
+		return "", fmt.Errorf("unsupported jump op %#x", uint8(jumpOp))
+	}
+	dst := regName(insn.Dst(), wide)
+	var rhs string
+	if insn.OpCode()&asm.ALUSrcReg != 0 {
+		rhs = regName(insn.Src(), wide)
+	} else {
+		rhs = fmt.Sprintf("%d", insn.Imm())
+	}
+	return fmt.Sprintf("if %s %s %s goto %s", dst, sym, rhs, printGotoTarget(insn, idx, all)), nil
+}
+
+// printGotoTarget renders a jump's offset as "+N" or "-N" and, if the target instruction carries a
+// label, appends it purely as a human-readable hint; Parse always trusts the numeric offset and
+// ignores the hint.
+func printGotoTarget(insn asm.Insn, idx int, all asm.Insns) string {
+	off := insn.Off()
+	target := fmt.Sprintf("%+d", off)
+	targetIdx := idx + 1 + int(off)
+	if targetIdx >= 0 && targetIdx < len(all) && len(all[targetIdx].Labels) > 0 {
+		target += " " + all[targetIdx].Labels[0]
+	}
+	return target
+}
+
+func printStoreRegOrAtomic(insn asm.Insn) (string, error) {
+	size, err := sizeName(insn.OpCode())
+	if err != nil {
+		return "", err
+	}
+	dst := regName(insn.Dst(), true)
+	src := regName(insn.Src(), true)
+
+	if insn.OpCode()&0b111_00_000 == asm.MemOpModeAtomic {
+		subOp := insn.Imm()
+		if name, ok := atomicCallNameForSubOp(subOp); ok {
+			return fmt.Sprintf("lock *(%s *)(%s %s) = %s(%s)", size, dst, offsetExpr(insn.Off()), name, src), nil
+		}
+		if sym, ok := atomicCompoundSyms[subOp]; ok {
+			return fmt.Sprintf("lock *(%s *)(%s %s) %s %s", size, dst, offsetExpr(insn.Off()), sym, src), nil
+		}
+		return "", fmt.Errorf("unrecognised atomic sub-op %#x", uint8(subOp))
+	}
+
+	return fmt.Sprintf("*(%s *)(%s %s) = %s", size, dst, offsetExpr(insn.Off()), src), nil
+}
@@ -0,0 +1,239 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package text is the AsmParser/AsmPrinter half of the asm package: Parse and Print convert
+// between asm.Insns and a syntax close to `bpftool prog dump xlated` / `llvm-objdump -d`, e.g.
+//
+//	r1 = *(u64 *)(r2 + 8)
+//	if r3 == 0 goto +5 done
+//	lock *(u32 *)(r10 - 4) += r0
+//	call 12
+//	r1 = map_fd(3) ll
+//
+// Print is the inverse of Parse for any Insns built from the opcodes the grammar below covers:
+// Parse(Print(insns)) reproduces insns byte-for-byte, including Labels/Comments/Annotation. The
+// grammar covers the instruction shapes Block and VBlock emit, except BPF-to-BPF calls
+// (CallSubprogram) and long jumps (LongJumpA); Print returns an error for those and for any other
+// opcode outside the grammar rather than guessing a syntax.
+package text
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/projectcalico/calico/felix/bpf/asm"
+)
+
+// aluOp pairs an ALUOp nibble with the compound-assignment operator used for it in both
+// directions of the grammar. Negate ("dst = -dst") and Endian ("dst = be64 dst") have their own
+// syntax below because they don't fit the "dst OP= rhs" shape.
+type aluOp struct {
+	op  asm.OpCode
+	sym string
+}
+
+var aluOps = []aluOp{
+	{asm.ALUOpAdd, "+="},
+	{asm.ALUOpSub, "-="},
+	{asm.ALUOpMul, "*="},
+	{asm.ALUOpDiv, "/="},
+	{asm.ALUOpOr, "|="},
+	{asm.ALUOpAnd, "&="},
+	{asm.ALUOpShiftL, "<<="},
+	{asm.ALUOpShiftR, ">>="},
+	{asm.ALUOpMod, "%="},
+	{asm.ALUOpXOR, "^="},
+	{asm.ALUOpMov, "="},
+	{asm.ALUOpAShiftR, "s>>="},
+}
+
+func aluSymForOp(op asm.OpCode) (string, bool) {
+	for _, a := range aluOps {
+		if a.op == op {
+			return a.sym, true
+		}
+	}
+	return "", false
+}
+
+func aluOpForSym(sym string) (asm.OpCode, bool) {
+	for _, a := range aluOps {
+		if a.sym == sym {
+			return a.op, true
+		}
+	}
+	return 0, false
+}
+
+// jumpOp pairs a JumpOp nibble with the comparison operator used in "if dst OP rhs goto ..."
+// text. JumpOpA/JumpOpCall/JumpOpExit have their own syntax below.
+type jumpOp struct {
+	op  asm.OpCode
+	sym string
+}
+
+var jumpOps = []jumpOp{
+	{asm.JumpOpEq, "=="},
+	{asm.JumpOpGT, ">"},
+	{asm.JumpOpGE, ">="},
+	{asm.JumpOpSet, "&"},
+	{asm.JumpOpNE, "!="},
+	{asm.JumpOpSGT, "s>"},
+	{asm.JumpOpSGE, "s>="},
+	{asm.JumpOpLT, "<"},
+	{asm.JumpOpLE, "<="},
+	{asm.JumpOpSLT, "s<"},
+	{asm.JumpOpSLE, "s<="},
+}
+
+func jumpSymForOp(op asm.OpCode) (string, bool) {
+	for _, j := range jumpOps {
+		if j.op == op {
+			return j.sym, true
+		}
+	}
+	return "", false
+}
+
+func jumpOpForSym(sym string) (asm.OpCode, bool) {
+	for _, j := range jumpOps {
+		if j.sym == sym {
+			return j.op, true
+		}
+	}
+	return 0, false
+}
+
+// atomicCompoundSyms covers the non-fetching atomic ops, which read as a familiar compound
+// assignment: "lock *(u32 *)(r10 - 4) += r0".
+var atomicCompoundSyms = map[int32]string{
+	asm.AtomicOpAdd: "+=",
+	asm.AtomicOpOr:  "|=",
+	asm.AtomicOpAnd: "&=",
+	asm.AtomicOpXor: "^=",
+}
+
+// atomicCallNames covers the fetching ops and xchg/cmpxchg, which read src back out (or swap it
+// in), so they're rendered as a call-like form instead: "lock *(u64 *)(r1 + 0) = atomic_xchg(r2)".
+// The names match the ones buildAnnotation already uses for debug output, for consistency.
+var atomicCallNames = map[int32]string{
+	asm.AtomicOpAdd | asm.AtomicOpFetch: "atomic_fetch_add",
+	asm.AtomicOpOr | asm.AtomicOpFetch:  "atomic_fetch_or",
+	asm.AtomicOpAnd | asm.AtomicOpFetch: "atomic_fetch_and",
+	asm.AtomicOpXor | asm.AtomicOpFetch: "atomic_fetch_xor",
+	asm.AtomicOpXchg:                    "atomic_xchg",
+	asm.AtomicOpCmpXchg:                 "atomic_cmpxchg",
+}
+
+func atomicCallNameForSubOp(subOp int32) (string, bool) {
+	n, ok := atomicCallNames[subOp]
+	return n, ok
+}
+
+func atomicSubOpForCallName(name string) (int32, bool) {
+	for subOp, n := range atomicCallNames {
+		if n == name {
+			return subOp, true
+		}
+	}
+	return 0, false
+}
+
+func atomicSubOpForCompoundSym(sym string) (int32, bool) {
+	for subOp, s := range atomicCompoundSyms {
+		if s == sym {
+			return subOp, true
+		}
+	}
+	return 0, false
+}
+
+// regName renders a register name: "r0".."r10" for 64-bit operands, "w0".."w9" for 32-bit ones,
+// matching the kernel/bpftool convention of using the w-prefix to mean "low 32 bits of rN".
+func regName(r asm.Reg, wide bool) string {
+	if wide {
+		return fmt.Sprintf("r%d", r)
+	}
+	return fmt.Sprintf("w%d", r)
+}
+
+// parseReg parses a register name produced by regName, returning whether it was 64-bit (r) or
+// 32-bit (w).
+func parseReg(s string) (asm.Reg, bool, error) {
+	if len(s) < 2 {
+		return 0, false, fmt.Errorf("not a register: %q", s)
+	}
+	var wide bool
+	switch s[0] {
+	case 'r':
+		wide = true
+	case 'w':
+		wide = false
+	default:
+		return 0, false, fmt.Errorf("not a register: %q", s)
+	}
+	n, err := strconv.Atoi(s[1:])
+	if err != nil {
+		return 0, false, fmt.Errorf("not a register: %q", s)
+	}
+	return asm.Reg(n), wide, nil
+}
+
+func isReg(s string) bool {
+	_, _, err := parseReg(s)
+	return err == nil
+}
+
+// sizeName renders the MemOpSize bits of opcode as the "uN" cast used in load/store/atomic text.
+func sizeName(opcode asm.OpCode) (string, error) {
+	switch opcode & 0b000_11_000 {
+	case asm.MemOpSize8:
+		return "u8", nil
+	case asm.MemOpSize16:
+		return "u16", nil
+	case asm.MemOpSize32:
+		return "u32", nil
+	case asm.MemOpSize64:
+		return "u64", nil
+	}
+	return "", fmt.Errorf("unrecognised size bits in opcode %#x", uint8(opcode))
+}
+
+func sizeMask(name string) (asm.OpCode, error) {
+	switch name {
+	case "u8":
+		return asm.MemOpSize8, nil
+	case "u16":
+		return asm.MemOpSize16, nil
+	case "u32":
+		return asm.MemOpSize32, nil
+	case "u64":
+		return asm.MemOpSize64, nil
+	}
+	return 0, fmt.Errorf("unrecognised size %q", name)
+}
+
+// offsetExpr renders a FieldOffset-style offset the way the grammar's examples do -- "r10 - 4"
+// rather than "r10 + -4".
+func offsetExpr(off int16) string {
+	if off < 0 {
+		return fmt.Sprintf("- %d", -int32(off))
+	}
+	return fmt.Sprintf("+ %d", off)
+}
+
+// annotationSep separates an instruction's text from its preserved Insn.Annotation. Print emits it
+// ahead of every non-empty annotation so that Parse can recover Annotation; annotations are assumed to be
+// single-line, which holds for every annotation Block/VBlock currently generate.
+const annotationSep = "\t### "
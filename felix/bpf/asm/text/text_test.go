@@ -0,0 +1,60 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package text
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/projectcalico/calico/felix/bpf/asm"
+)
+
+// TestParsePrintRoundTrip builds a block covering ALU ops, loads/stores, an atomic op, a
+// LoadImm64, a conditional and unconditional jump, and exit, then checks that
+// Parse(Print(insns)) reproduces the original instruction bytes, as promised by the package doc
+// comment.
+func TestParsePrintRoundTrip(t *testing.T) {
+	b := asm.NewBlock(false)
+	b.MovImm64(asm.R1, 7)
+	b.AddImm64(asm.R1, 1)
+	b.Load64(asm.R2, asm.R10, asm.FieldOffset{Offset: -8})
+	b.Store64(asm.R2, asm.R10, asm.FieldOffset{Offset: -16})
+	b.AtomicAdd64(asm.R10, asm.R1, asm.FieldOffset{Offset: -8})
+	b.LoadImm64(asm.R3, 0x1122334455)
+	b.JumpEqImm64(asm.R2, 7, "done") // R2 is loaded from memory, so Assemble can't fold this away.
+	b.Jump("done")
+	b.LabelNextInsn("done")
+	b.Exit()
+
+	want, err := b.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble() returned an error: %v", err)
+	}
+
+	printed, err := Print(want)
+	if err != nil {
+		t.Fatalf("Print() returned an error: %v", err)
+	}
+
+	got, err := Parse(strings.NewReader(printed))
+	if err != nil {
+		t.Fatalf("Parse() returned an error: %v\ninput:\n%s", err, printed)
+	}
+
+	if !bytes.Equal(want.AsBytes(), got.AsBytes()) {
+		t.Fatalf("round trip did not reproduce the original instructions\nprinted:\n%s", printed)
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asm
+
+import (
+	"fmt"
+	"math"
+)
+
+// maxBackwardStride is the largest (in magnitude) backwards hop a single trampoline JumpA can
+// make; kept comfortably inside the int16 offset range, mirroring TrampolineStrideDefault.
+const maxBackwardStride = math.MaxInt16 - trampolineHeadroom
+
+// resolveBackwardOverflows is run from Assemble, before offsets are finally baked into the
+// instruction bytes. It repeatedly scans outstanding fix-ups for backwards branches whose offset
+// would overflow the 16-bit jump range and, for each one, splices in a chain of backwards JumpA
+// "constant island" trampolines -- the same idea LLVM's ConstantIslandPass uses for long ARM/
+// AArch64 branches. A branch (conditional or not) that's too far back to reach its label directly
+// is instead redirected at the nearest trampoline hop; fix-up resolution then just needs to fill
+// in the (now in-range) offset, same as for any other jump. Splicing shifts every instruction
+// index after the insertion point, so the pass iterates to a fixed point: each round can reveal
+// (or resolve) other branches whose range now crosses an inserted trampoline.
+func (b *Block) resolveBackwardOverflows() error {
+	for {
+		origIdx, label, ok := b.findBackwardOverflow()
+		if !ok {
+			return nil
+		}
+
+		labelIdx, found := b.labelToInsnIdx[label]
+		if !found {
+			return fmt.Errorf("resolving backward jump: missing label %s", label)
+		}
+
+		if err := b.spliceBackwardTrampoline(origIdx, labelIdx, label); err != nil {
+			return err
+		}
+	}
+}
+
+// findBackwardOverflow returns the first outstanding fix-up whose resolved offset would be a
+// backwards branch that overflows int16, or ok=false if there are none left.
+func (b *Block) findBackwardOverflow() (origIdx int, label string, ok bool) {
+	for l, fixups := range b.fixUps {
+		labelIdx, found := b.labelToInsnIdx[l]
+		if !found {
+			continue
+		}
+		for _, fu := range fixups {
+			if fu.useImm {
+				continue // target goes in the 32-bit imm field; no 16-bit range to overflow.
+			}
+			offset := labelIdx - fu.origInsnIdx - 1
+			if offset >= 0 || offset >= math.MinInt16 {
+				continue // forward, or fits as-is; not our concern here.
+			}
+			return fu.origInsnIdx, l, true
+		}
+	}
+	return 0, "", false
+}
+
+// spliceBackwardTrampoline redirects the branch at origIdx (which targets label, too far back to
+// reach directly) at a single freshly inserted JumpA hop, placed maxBackwardStride instructions
+// back from origIdx (clamped so it never lands before label itself). The hop's own fix-up then
+// targets the real label, at the now-shorter remaining distance. If that's still out of range,
+// it's just another outstanding backward fix-up, so the next trip around resolveBackwardOverflows'
+// loop finds it and splices another hop from it -- walking the chain back one stride at a time
+// until a hop is close enough to label to reach it directly. Each call strictly reduces the
+// distance still to be covered, so the loop is guaranteed to terminate.
+func (b *Block) spliceBackwardTrampoline(origIdx, labelIdx int, label string) error {
+	hopIdx := origIdx - maxBackwardStride
+	if hopIdx <= labelIdx {
+		hopIdx = labelIdx + 1
+	}
+
+	if err := b.insertInsns(hopIdx, Insns{MakeInsn(JumpA, 0, 0, 0, 0)}); err != nil {
+		return err
+	}
+	if origIdx >= hopIdx {
+		origIdx++
+	}
+
+	b.trampolineIdx++
+	hopLabel := fmt.Sprintf("backward-trampoline-%d", b.trampolineIdx)
+	b.labelInsnAt(hopIdx, hopLabel)
+
+	// Redirect the original branch at the hop instead of the real (too-distant) label.
+	b.removeFixUp(label, origIdx)
+	b.fixUps[hopLabel] = append(b.fixUps[hopLabel], fixUp{origInsnIdx: origIdx})
+	b.inUseJumpTargets.Add(hopLabel)
+
+	// The hop itself still needs to reach the real label, possibly via further hops.
+	b.fixUps[label] = append(b.fixUps[label], fixUp{origInsnIdx: hopIdx})
+	b.inUseJumpTargets.Add(label)
+
+	return nil
+}
+
+// removeFixUp deletes the fix-up entry for origInsnIdx under label, if present.
+func (b *Block) removeFixUp(label string, origInsnIdx int) {
+	fixups := b.fixUps[label]
+	for i, fu := range fixups {
+		if fu.origInsnIdx == origInsnIdx {
+			b.fixUps[label] = append(fixups[:i], fixups[i+1:]...)
+			return
+		}
+	}
+}
+
+// labelInsnAt attaches a label to an already-emitted instruction at idx (as opposed to
+// LabelNextInsn, which labels the next instruction to be appended).
+func (b *Block) labelInsnAt(idx int, label string) {
+	b.labelToInsnIdx[label] = idx
+	b.insnIdxToLabels[idx] = append(b.insnIdxToLabels[idx], label)
+}
+
+// insertInsns splices newInsns into the instruction stream at idx, shifting every later
+// instruction (and the bookkeeping maps that reference instruction indices by position) forward
+// by len(newInsns).
+func (b *Block) insertInsns(idx int, newInsns Insns) error {
+	if idx < 0 || idx > len(b.insns) {
+		return fmt.Errorf("insertInsns: index %d out of range (len=%d)", idx, len(b.insns))
+	}
+	shift := len(newInsns)
+	if shift == 0 {
+		return nil
+	}
+
+	out := make(Insns, 0, len(b.insns)+shift)
+	out = append(out, b.insns[:idx]...)
+	out = append(out, newInsns...)
+	out = append(out, b.insns[idx:]...)
+	b.insns = out
+
+	shiftIndexMap := func(m map[int][]string) {
+		updated := make(map[int][]string, len(m))
+		for i, v := range m {
+			if i >= idx {
+				updated[i+shift] = v
+			} else {
+				updated[i] = v
+			}
+		}
+		for k := range m {
+			delete(m, k)
+		}
+		for k, v := range updated {
+			m[k] = v
+		}
+	}
+	shiftIndexMap(b.insnIdxToLabels)
+	shiftIndexMap(b.insnIdxToComments)
+
+	for label, idxVal := range b.labelToInsnIdx {
+		if idxVal >= idx {
+			b.labelToInsnIdx[label] = idxVal + shift
+		}
+	}
+	for label, fixups := range b.fixUps {
+		for i := range fixups {
+			if fixups[i].origInsnIdx >= idx {
+				fixups[i].origInsnIdx += shift
+			}
+		}
+		b.fixUps[label] = fixups
+	}
+
+	return nil
+}
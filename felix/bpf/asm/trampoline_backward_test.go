@@ -0,0 +1,59 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asm
+
+import (
+	"testing"
+	"time"
+)
+
+// TestLongBackwardJumpResolves builds a block whose backward branch is tens of thousands of
+// instructions from its label -- comfortably past maxBackwardStride -- and checks that Assemble
+// still terminates and produces an in-range jump. resolveBackwardOverflows used to spin forever on
+// an input like this, because spliceBackwardTrampoline inserted every hop right next to the branch
+// instead of spreading them back towards the label.
+func TestLongBackwardJumpResolves(t *testing.T) {
+	const numNoOps = 40000 // comfortably more than maxBackwardStride apart from the branch below.
+
+	b := NewBlock(false)
+	b.LabelNextInsn("loop-start")
+	for i := 0; i < numNoOps; i++ {
+		b.NoOp()
+	}
+	b.JumpNEImm64(R1, 0, "loop-start")
+	b.Exit()
+
+	type result struct {
+		insns Insns
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		insns, err := b.Assemble()
+		done <- result{insns, err}
+	}()
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			t.Fatalf("Assemble() returned an error: %v", r.err)
+		}
+		if len(r.insns) == 0 {
+			t.Fatal("Assemble() returned no instructions")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Assemble() did not return within 5s; resolveBackwardOverflows looks stuck in an infinite loop")
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asm
+
+
+// VReg is a virtual register: an SSA-ish value that VBlock allocates to one of R1-R9 (or a stack
+// spill slot) at Assemble() time. Callers that don't want to hand-allocate R0-R9 themselves can
+// build a VBlock instead of a Block and let the allocator do it, similar in spirit to how
+// Cranelift's aarch64 backend separates virtual registers from the real-register universe used by
+// its linear-scan allocator.
+//
+// R0 is deliberately never handed out to a VReg: it's reserved as the scratch register the
+// allocator uses to reload spilled values immediately before the instruction that needs them, and
+// as the Call/Exit ABI register. Callers that need the result of Call or the value passed to Exit
+// name a VReg for it and the allocator inserts the R0 <-> VReg shuffling itself.
+type VReg int
+
+// noVReg marks an unused register operand slot on a vInsn.
+const noVReg VReg = -1
+
+// NewVRegAllocator returns a fresh allocator for handing out distinct VReg values.
+func NewVRegAllocator() *VRegAllocator {
+	return &VRegAllocator{}
+}
+
+type VRegAllocator struct {
+	next VReg
+}
+
+func (a *VRegAllocator) NewVReg() VReg {
+	v := a.next
+	a.next++
+	return v
+}
+
+// vInsn is one virtual instruction: a real Insn template plus the VRegs it reads (use) and
+// writes (def), used to compute live ranges. regA/regB follow the same positional convention as
+// Block.add's (dst, src): for memory ops regA is the pointer register and regB is the value.
+type vInsn struct {
+	opcode OpCode
+	regA   VReg
+	regB   VReg
+	useA   bool
+	defA   bool
+	useB   bool
+	defB   bool
+
+	offset int16
+	imm    int32
+	label  string // set for instructions with a jump fix-up
+
+	isCall      bool
+	callHelper  Helper
+	callSubprog string // set instead of callHelper for a CallSubprogram
+	callArgs    []VReg // VRegs that must be in R1.. before the call, in order
+	callResult  VReg   // VReg that receives R0 after the call, noVReg if unused
+
+	isExit    bool
+	exitValue VReg // VReg that must be moved into R0 before Exit, noVReg if unused
+
+	isLabelDef bool // pseudo-instruction: just defines a label, no real Insn emitted
+}
+
+// VBlock mirrors the subset of Block's API that's useful for register-agnostic code: callers
+// build up a sequence of virtual instructions against VRegs, then VBlock.Assemble() runs a
+// linear-scan allocator over the live ranges and emits a real Block, spilling to the stack where
+// necessary.
+type VBlock struct {
+	*VRegAllocator
+	policyDebugEnabled bool
+
+	insns      []vInsn
+	labelToIdx map[string]int
+	insnLabels map[int][]string
+}
+
+func NewVBlock(allocator *VRegAllocator, policyDebugEnabled bool) *VBlock {
+	return &VBlock{
+		VRegAllocator:      allocator,
+		policyDebugEnabled: policyDebugEnabled,
+		labelToIdx:         map[string]int{},
+		insnLabels:         map[int][]string{},
+	}
+}
+
+func (vb *VBlock) LabelNextInsn(label string) {
+	idx := len(vb.insns)
+	vb.labelToIdx[label] = idx
+	vb.insnLabels[idx] = append(vb.insnLabels[idx], label)
+	vb.insns = append(vb.insns, vInsn{isLabelDef: true, regA: noVReg, regB: noVReg})
+}
+
+func (vb *VBlock) Mov64(dst, src VReg) {
+	vb.insns = append(vb.insns, vInsn{opcode: Mov64, regA: dst, regB: src, defA: true, useB: true})
+}
+
+func (vb *VBlock) MovImm64(dst VReg, imm int32) {
+	vb.insns = append(vb.insns, vInsn{opcode: MovImm64, regA: dst, regB: noVReg, defA: true, imm: imm})
+}
+
+func (vb *VBlock) Add64(dst, src VReg) {
+	vb.insns = append(vb.insns, vInsn{opcode: Add64, regA: dst, regB: src, defA: true, useA: true, useB: true})
+}
+
+func (vb *VBlock) AddImm64(dst VReg, imm int32) {
+	vb.insns = append(vb.insns, vInsn{opcode: AddImm64, regA: dst, regB: noVReg, defA: true, useA: true, imm: imm})
+}
+
+// Load64 loads *(u64 *)(ptr + fo.Offset) into dst.
+func (vb *VBlock) Load64(dst, ptr VReg, fo FieldOffset) {
+	vb.insns = append(vb.insns, vInsn{opcode: LoadReg64, regA: ptr, regB: dst, useA: true, defB: true, offset: fo.Offset})
+}
+
+// Store64 stores value into *(u64 *)(ptr + fo.Offset).
+func (vb *VBlock) Store64(value, ptr VReg, fo FieldOffset) {
+	vb.insns = append(vb.insns, vInsn{opcode: StoreReg64, regA: ptr, regB: value, useA: true, useB: true, offset: fo.Offset})
+}
+
+func (vb *VBlock) JumpEqImm64(ra VReg, imm int32, label string) {
+	vb.insns = append(vb.insns, vInsn{opcode: JumpEqImm64, regA: ra, regB: noVReg, useA: true, imm: imm, label: label})
+}
+
+func (vb *VBlock) JumpNEImm64(ra VReg, imm int32, label string) {
+	vb.insns = append(vb.insns, vInsn{opcode: JumpNEImm64, regA: ra, regB: noVReg, useA: true, imm: imm, label: label})
+}
+
+func (vb *VBlock) Jump(label string) {
+	vb.insns = append(vb.insns, vInsn{opcode: JumpA, regA: noVReg, regB: noVReg, label: label})
+}
+
+// Call models a helper call: argVRegs must already hold the values the helper expects in
+// R1..R5 (in order), and resultVReg (noVReg if the result is unused) receives R0 afterwards. Per
+// the BPF ABI, R1-R5 are clobbered by any call; the allocator treats every VReg live across a
+// Call as needing a callee-saved (R6-R9) home or a stack spill.
+func (vb *VBlock) Call(helper Helper, argVRegs []VReg, resultVReg VReg) {
+	vb.insns = append(vb.insns, vInsn{
+		isCall: true, opcode: Call, regA: noVReg, regB: noVReg,
+		callHelper: helper, callArgs: argVRegs, callResult: resultVReg,
+	})
+}
+
+// CallSubprogram models a BPF-to-BPF call to the subprogram that name will be registered under via
+// Block.DefineSubprogram once this VBlock is assembled: argVRegs must already hold the values the
+// subprogram expects in R1..R5 (in order), and resultVReg (noVReg if the result is unused) receives
+// R0 afterwards. Same clobbering rules as Call: R1-R5 don't survive the call, so a VReg live across
+// it needs a callee-saved (R6-R9) home or a stack spill.
+//
+// Note: VBlock.Assemble builds and assembles its own Block internally and never calls
+// Block.DefineSubprogram on it, so Assemble returns a "missing label" error for any VBlock that
+// uses CallSubprogram.
+func (vb *VBlock) CallSubprogram(name string, argVRegs []VReg, resultVReg VReg) {
+	vb.insns = append(vb.insns, vInsn{
+		isCall: true, opcode: Call, regA: noVReg, regB: noVReg,
+		callSubprog: name, callArgs: argVRegs, callResult: resultVReg,
+	})
+}
+
+// Exit moves valueVReg into R0 (if valueVReg != noVReg) and emits Exit.
+func (vb *VBlock) Exit(valueVReg VReg) {
+	vb.insns = append(vb.insns, vInsn{isExit: true, opcode: Exit, regA: noVReg, regB: noVReg, exitValue: valueVReg})
+}
@@ -0,0 +1,342 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package asm
+
+import "fmt"
+
+// liveRange is the inclusive [start, end] instruction-index span over which a VReg holds a live
+// value, computed with a simple linear scan over the (already control-flow-flattened)
+// instruction stream. This over-approximates true liveness across branches (it doesn't do a
+// proper dataflow fixed point across jumps), which is conservative: it may keep a register
+// reserved for longer than strictly necessary, but it never under-allocates.
+type liveRange struct {
+	vreg        VReg
+	start, end  int
+	crossesCall bool
+}
+
+// spillSlot is a VReg's stack home, once the allocator decides it can't live in a real register
+// for its whole range.
+type spillSlot struct {
+	offset int16 // negative, relative to R10
+}
+
+const spillSlotSize = 8
+
+// scratchReg is the one real register the allocator reserves for itself, used to reload a
+// spilled VReg immediately before the instruction that needs it (and to store it back after a
+// def). It is never handed out to a VReg.
+//
+// Limitation: because there's only one scratch register, an instruction whose two register
+// operands are *both* spilled VRegs (e.g. Add64, Store64) can't be represented faithfully --
+// there's nowhere to hold the second value without clobbering the first. Assemble detects this
+// case and returns an error rather than silently emitting an instruction that operates on the
+// same register twice; it would need a second scratch register to support it instead.
+const scratchReg = R0
+
+// Assemble computes live ranges for every VReg, allocates R1-R9 (spilling to R10-relative stack
+// slots on overflow) via linear scan, and emits the resulting real Block.
+func (vb *VBlock) Assemble() (Insns, error) {
+	ranges := vb.computeLiveRanges()
+	homes, numSpills := vb.allocate(ranges)
+
+	b := NewBlock(vb.policyDebugEnabled)
+	if numSpills > 0 {
+		b.AddCommentF("vreg allocator spilled %d virtual register(s) to the stack", numSpills)
+	}
+
+	reg := func(v VReg) (Reg, bool) {
+		if v == noVReg {
+			return 0, false
+		}
+		h := homes[v]
+		if h.isSpill {
+			return 0, false
+		}
+		return h.real, true
+	}
+
+	// reload emits a LoadStack64 for v (if spilled) into scratchReg and returns the Reg to use.
+	// v must name a VReg that already holds a live value -- callers must not reload a VReg that
+	// is only being defined by the current instruction (use resolveDef for that).
+	reload := func(v VReg) Reg {
+		if v == noVReg {
+			return 0
+		}
+		if r, ok := reg(v); ok {
+			return r
+		}
+		b.LoadStack64(scratchReg, FieldOffset{Offset: homes[v].slot.offset})
+		b.AddCommentF("reload spilled vreg%d", v)
+		return scratchReg
+	}
+	// resolveDef returns the Reg a pure-def (no prior value) operand should be written into,
+	// without emitting a reload (there's nothing valid to reload yet).
+	resolveDef := func(v VReg) Reg {
+		if r, ok := reg(v); ok {
+			return r
+		}
+		return scratchReg
+	}
+	// spillStore emits a StoreStack64 for v (if spilled) from scratchReg.
+	spillStore := func(v VReg) {
+		if _, ok := reg(v); ok {
+			return
+		}
+		b.StoreStack64(scratchReg, homes[v].slot.offset)
+		b.AddCommentF("spill vreg%d", v)
+	}
+
+	for idx, vi := range vb.insns {
+		for _, label := range vb.insnLabels[idx] {
+			b.LabelNextInsn(label)
+		}
+
+		switch {
+		case vi.isLabelDef:
+			continue
+		case vi.isCall:
+			for i, arg := range vi.callArgs {
+				src := reload(arg)
+				b.Mov64(Reg(int(R1)+i), src)
+			}
+			if vi.callSubprog != "" {
+				b.CallSubprogram(vi.callSubprog)
+			} else {
+				b.Call(vi.callHelper)
+			}
+			if vi.callResult != noVReg {
+				if r, ok := reg(vi.callResult); ok {
+					b.Mov64(r, R0)
+				} else {
+					b.StoreStack64(R0, homes[vi.callResult].slot.offset)
+				}
+			}
+			continue
+		case vi.isExit:
+			if vi.exitValue != noVReg {
+				src := reload(vi.exitValue)
+				if src != R0 {
+					b.Mov64(R0, src)
+				}
+			}
+			b.Exit()
+			continue
+		}
+
+		if vi.useA && vi.useB && vi.regA != vi.regB {
+			_, aOK := reg(vi.regA)
+			_, bOK := reg(vi.regB)
+			if !aOK && !bOK {
+				// Both operands are spilled and both need to be live at once (e.g. Add64,
+				// Store64): there's only one scratch register, so the second reload would
+				// clobber the first before the instruction ever executes. See the Limitation
+				// note on scratchReg -- bail out rather than silently emit wrong code.
+				return nil, fmt.Errorf("vreg allocator: vreg%d and vreg%d are both spilled but instruction %v needs both live at once; only one scratch register is available", vi.regA, vi.regB, vi.opcode)
+			}
+		}
+
+		var dstReg Reg
+		if vi.useA {
+			dstReg = reload(vi.regA)
+		} else if vi.defA {
+			dstReg = resolveDef(vi.regA)
+		}
+		var srcReg Reg
+		if vi.useB {
+			srcReg = reload(vi.regB)
+		} else if vi.defB {
+			srcReg = resolveDef(vi.regB)
+		}
+
+		switch vi.opcode {
+		case Mov64:
+			b.Mov64(dstReg, srcReg)
+		case MovImm64:
+			b.MovImm64(dstReg, vi.imm)
+		case Add64:
+			b.Add64(dstReg, srcReg)
+		case AddImm64:
+			b.AddImm64(dstReg, vi.imm)
+		case LoadReg64:
+			b.Load64(srcReg, dstReg, FieldOffset{Offset: vi.offset})
+		case StoreReg64:
+			b.Store64(srcReg, dstReg, FieldOffset{Offset: vi.offset})
+		case JumpEqImm64:
+			b.JumpEqImm64(dstReg, vi.imm, vi.label)
+		case JumpNEImm64:
+			b.JumpNEImm64(dstReg, vi.imm, vi.label)
+		case JumpA:
+			b.Jump(vi.label)
+		default:
+			return nil, fmt.Errorf("vreg allocator: unsupported virtual opcode %v", vi.opcode)
+		}
+
+		if vi.defA {
+			spillStore(vi.regA)
+		}
+		if vi.defB {
+			spillStore(vi.regB)
+		}
+	}
+
+	return b.Assemble()
+}
+
+func (vb *VBlock) computeLiveRanges() map[VReg]*liveRange {
+	ranges := map[VReg]*liveRange{}
+	touch := func(v VReg, idx int) {
+		if v == noVReg {
+			return
+		}
+		r, ok := ranges[v]
+		if !ok {
+			ranges[v] = &liveRange{vreg: v, start: idx, end: idx}
+			return
+		}
+		if idx < r.start {
+			r.start = idx
+		}
+		if idx > r.end {
+			r.end = idx
+		}
+	}
+
+	callIdxs := make([]int, 0)
+	for idx, vi := range vb.insns {
+		if vi.isCall {
+			callIdxs = append(callIdxs, idx)
+			for _, a := range vi.callArgs {
+				touch(a, idx)
+			}
+			touch(vi.callResult, idx)
+			continue
+		}
+		if vi.isExit {
+			touch(vi.exitValue, idx)
+			continue
+		}
+		touch(vi.regA, idx)
+		touch(vi.regB, idx)
+	}
+
+	// A backward jump (a loop) carries every value live at its target round again.
+	for changed := true; changed; {
+		changed = false
+		for idx, vi := range vb.insns {
+			if target, ok := vb.labelToIdx[vi.label]; vi.label != "" && ok && target <= idx {
+				for _, r := range ranges {
+					if r.start < target && r.end >= target && r.end < idx {
+						r.end, changed = idx, true
+					}
+				}
+			}
+		}
+	}
+
+	for _, r := range ranges {
+		for _, callIdx := range callIdxs {
+			if r.start <= callIdx && callIdx <= r.end {
+				r.crossesCall = true
+				break
+			}
+		}
+	}
+	return ranges
+}
+
+type regHome struct {
+	isSpill bool
+	real    Reg
+	slot    spillSlot
+}
+
+// allocate runs a classic linear-scan allocator: ranges are processed in order of increasing
+// start, with two free pools (callee-saved R6-R9, scratch R1-R5) replenished as earlier ranges
+// expire. Ranges that cross a Call must land in the callee-saved pool (or be spilled); everything
+// else prefers the scratch pool to leave callee-saved registers free for longer-lived values.
+func (vb *VBlock) allocate(ranges map[VReg]*liveRange) (map[VReg]regHome, int) {
+	ordered := make([]*liveRange, 0, len(ranges))
+	for _, r := range ranges {
+		ordered = append(ordered, r)
+	}
+	sortRangesByStart(ordered)
+
+	calleeSaved := []Reg{R6, R7, R8, R9}
+	scratch := []Reg{R1, R2, R3, R4, R5}
+
+	type active struct {
+		r    *liveRange
+		reg  Reg
+		pool *[]Reg
+	}
+	var activeList []active
+
+	homes := make(map[VReg]regHome, len(ordered))
+	nextSlot := int16(0)
+	numSpills := 0
+
+	expire := func(upTo int) {
+		kept := activeList[:0]
+		for _, a := range activeList {
+			if a.r.end < upTo {
+				*a.pool = append(*a.pool, a.reg)
+			} else {
+				kept = append(kept, a)
+			}
+		}
+		activeList = kept
+	}
+
+	for _, r := range ordered {
+		expire(r.start)
+
+		var pool *[]Reg
+		if r.crossesCall {
+			pool = &calleeSaved
+		} else if len(scratch) > 0 {
+			pool = &scratch
+		} else {
+			pool = &calleeSaved
+		}
+
+		if len(*pool) == 0 && pool == &scratch {
+			pool = &calleeSaved
+		}
+
+		if len(*pool) > 0 {
+			reg := (*pool)[len(*pool)-1]
+			*pool = (*pool)[:len(*pool)-1]
+			homes[r.vreg] = regHome{real: reg}
+			activeList = append(activeList, active{r: r, reg: reg, pool: pool})
+			continue
+		}
+
+		// No free real register left: spill.
+		nextSlot -= spillSlotSize
+		homes[r.vreg] = regHome{isSpill: true, slot: spillSlot{offset: nextSlot}}
+		numSpills++
+	}
+
+	return homes, numSpills
+}
+
+func sortRangesByStart(ranges []*liveRange) {
+	for i := 1; i < len(ranges); i++ {
+		for j := i; j > 0 && ranges[j].start < ranges[j-1].start; j-- {
+			ranges[j], ranges[j-1] = ranges[j-1], ranges[j]
+		}
+	}
+}
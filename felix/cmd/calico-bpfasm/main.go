@@ -0,0 +1,78 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// calico-bpfasm is a small developer tool that wraps asm/text's Parse/Print so that generated
+// eBPF programs can be inspected and hand-edited as text. By default it assembles: it reads the
+// text format from stdin and writes raw instruction bytes to stdout. With -d, it disassembles the
+// reverse direction: raw instruction bytes from stdin to text on stdout.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/projectcalico/calico/felix/bpf/asm"
+	"github.com/projectcalico/calico/felix/bpf/asm/text"
+)
+
+func main() {
+	disassemble := flag.Bool("d", false, "disassemble: read raw instruction bytes from stdin, write text to stdout")
+	optimize := flag.Bool("O", false, "apply the post-assembly peephole pass (jump threading, short-jump folding, dead-block removal) before emitting bytes")
+	flag.Parse()
+
+	var err error
+	if *disassemble {
+		err = disassembleStdin()
+	} else {
+		err = assembleStdin(*optimize)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "calico-bpfasm: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func assembleStdin(optimize bool) error {
+	insns, err := text.Parse(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("parsing text: %w", err)
+	}
+	if optimize {
+		insns = asm.PeepholeOptimize(insns)
+	}
+	_, err = os.Stdout.Write(insns.AsBytes())
+	return err
+}
+
+func disassembleStdin() error {
+	raw, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return fmt.Errorf("reading stdin: %w", err)
+	}
+	if len(raw)%asm.InstructionSize != 0 {
+		return fmt.Errorf("input length %d is not a multiple of the %d-byte instruction size", len(raw), asm.InstructionSize)
+	}
+	insns := make(asm.Insns, len(raw)/asm.InstructionSize)
+	for i := range insns {
+		copy(insns[i].Instruction[:], raw[i*asm.InstructionSize:(i+1)*asm.InstructionSize])
+	}
+	out, err := text.Print(insns)
+	if err != nil {
+		return fmt.Errorf("printing text: %w", err)
+	}
+	_, err = io.WriteString(os.Stdout, out)
+	return err
+}
@@ -0,0 +1,140 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dns resolves the FQDNs an EntityRule.DomainNames/NotDomainNames field references into
+// the IP sets a dataplane (e.g. Felix) programs in their place, re-resolving each domain on its
+// own DNS TTL rather than a single fixed refresh interval.
+package dns
+
+import (
+	"context"
+	"net/netip"
+	"sync"
+	"time"
+)
+
+// Lookup resolves name to its current address set and the TTL the answer may be cached for.
+type Lookup func(ctx context.Context, name string) (addrs []netip.Addr, ttl time.Duration, err error)
+
+// cacheEntry is a single domain's cached resolution.
+type cacheEntry struct {
+	addrs     []netip.Addr
+	expiresAt time.Time
+}
+
+// Resolver maintains a per-domain TTL cache of FQDN resolutions, consumed by a dataplane as an
+// IPSet. It does not resolve anything on its own schedule -- Resolve is called on demand (e.g. by
+// Watch, or directly by a caller that already has its own polling loop).
+type Resolver struct {
+	lookup Lookup
+	minTTL time.Duration
+	maxTTL time.Duration
+
+	mu      sync.RWMutex
+	entries map[string]cacheEntry
+}
+
+// NewResolver builds a Resolver that resolves domains via lookup, clamping every TTL lookup
+// returns to [minTTL, maxTTL] -- this bounds both the re-resolve traffic a domain with a very
+// short or absent TTL would otherwise cause, and how long a changed record can go unnoticed behind
+// a very long one.
+func NewResolver(lookup Lookup, minTTL, maxTTL time.Duration) *Resolver {
+	return &Resolver{
+		lookup:  lookup,
+		minTTL:  minTTL,
+		maxTTL:  maxTTL,
+		entries: map[string]cacheEntry{},
+	}
+}
+
+// Resolve returns name's current address set, re-resolving it via Lookup if its cached entry has
+// expired or doesn't exist yet.
+func (r *Resolver) Resolve(ctx context.Context, name string) ([]netip.Addr, error) {
+	r.mu.RLock()
+	e, ok := r.entries[name]
+	r.mu.RUnlock()
+	if ok && time.Now().Before(e.expiresAt) {
+		return e.addrs, nil
+	}
+
+	addrs, ttl, err := r.lookup(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if ttl < r.minTTL {
+		ttl = r.minTTL
+	}
+	if ttl > r.maxTTL {
+		ttl = r.maxTTL
+	}
+
+	r.mu.Lock()
+	r.entries[name] = cacheEntry{addrs: addrs, expiresAt: time.Now().Add(ttl)}
+	r.mu.Unlock()
+
+	return addrs, nil
+}
+
+// IPSet returns the deduplicated union of every currently-cached domain's addresses, for
+// programming into the dataplane in place of the DomainNames/NotDomainNames entries that named
+// them.
+func (r *Resolver) IPSet() []netip.Addr {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	seen := make(map[netip.Addr]bool)
+	var out []netip.Addr
+	for _, e := range r.entries {
+		for _, a := range e.addrs {
+			if !seen[a] {
+				seen[a] = true
+				out = append(out, a)
+			}
+		}
+	}
+	return out
+}
+
+// Watch resolves every domain in names immediately, then keeps re-resolving each one on its own
+// TTL, calling onChange after every successful resolution, until ctx is cancelled. Each domain is
+// watched on its own goroutine so that one domain's slow or failing lookups don't delay another's.
+func (r *Resolver) Watch(ctx context.Context, names []string, onChange func(domain string)) {
+	for _, name := range names {
+		go r.watchOne(ctx, name, onChange)
+	}
+}
+
+func (r *Resolver) watchOne(ctx context.Context, name string, onChange func(domain string)) {
+	for {
+		if _, err := r.Resolve(ctx, name); err == nil {
+			onChange(name)
+		}
+
+		r.mu.RLock()
+		e, ok := r.entries[name]
+		r.mu.RUnlock()
+		wait := r.minTTL
+		if ok {
+			if untilExpiry := time.Until(e.expiresAt); untilExpiry > 0 {
+				wait = untilExpiry
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+	}
+}
@@ -0,0 +1,353 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policyanalysis answers "would this connection be allowed" against a set of already
+// validated policy objects, without needing a live datastore or dataplane. It backs the
+// `calicoctl policy analyze` dry-run command: given the full policy/tier configuration and a
+// synthetic 5-tuple, it walks the same tier/policy/rule order Felix would and reports the ordered
+// list of rules that matched plus the final verdict.
+package policyanalysis
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	api "github.com/projectcalico/api/pkg/apis/projectcalico/v3"
+	"github.com/projectcalico/api/pkg/lib/numorstring"
+
+	"github.com/projectcalico/calico/libcalico-go/lib/selector"
+)
+
+// Endpoint describes one side of a connection for analysis purposes.
+type Endpoint struct {
+	Labels         map[string]string
+	Namespace      string
+	ServiceAccount string
+	IP             string
+}
+
+// Tuple is the synthetic connection to evaluate: who's talking to whom, over what, in which
+// direction. Direction determines whether a policy's Ingress or Egress rules apply to it.
+type Tuple struct {
+	Source      Endpoint
+	Destination Endpoint
+	Protocol    *numorstring.Protocol
+	Port        *numorstring.Port
+	Direction   api.PolicyType
+}
+
+// Clause identifies which part of a rule caused it to match or fail to match, for surfacing to
+// the user alongside the rule that matched.
+type Clause string
+
+const (
+	ClauseSelector          Clause = "selector"
+	ClauseNamespaceSelector Clause = "namespaceSelector"
+	ClauseNets              Clause = "nets"
+	ClauseNotNets           Clause = "notNets"
+	ClauseServices          Clause = "services"
+	ClauseServiceAccounts   Clause = "serviceAccounts"
+	ClausePorts             Clause = "ports"
+	ClauseNotPorts          Clause = "notPorts"
+	ClauseProtocol          Clause = "protocol"
+)
+
+// MatchedRule records one rule that matched the tuple, in evaluation order.
+type MatchedRule struct {
+	Tier      string
+	Policy    string
+	RuleIndex int
+	Action    api.Action
+	Clause    Clause
+}
+
+// Default is the verdict's Action when no tier ended in an Allow or Deny: no rule matched, or the
+// last rule that matched was a Pass. Analyze does not evaluate policies' own Spec.Selector, so it
+// reports no implicit end-of-tier deny: a tier in which no rule matched is passed over.
+const Default api.Action = "NoMatch"
+
+// Verdict is the outcome of analyzing a Tuple against a policy set: the final action and the
+// ordered trail of rules that led to it.
+type Verdict struct {
+	Action  api.Action
+	Matches []MatchedRule
+}
+
+// PolicySet is the full, already-validated configuration to analyze a Tuple against. Staged
+// policies are converted to their enforced form before evaluation (StagedActionDelete policies are
+// skipped, mirroring what removing them would do) so a user can preview a pending change.
+type PolicySet struct {
+	Tiers                 []api.Tier
+	GlobalNetworkPolicies []api.GlobalNetworkPolicy
+	NetworkPolicies       []api.NetworkPolicy
+	StagedNetworkPolicies []api.StagedNetworkPolicy
+}
+
+// tieredPolicy is a (Global)NetworkPolicy normalized to the fields the engine needs, tagged with
+// its tier so policies from every source can be sorted and walked together. NetworkPolicySpec
+// doesn't carry PreDNAT/DoNotTrack/ApplyOnForward -- those are GlobalNetworkPolicy-only -- so
+// they're carried alongside rather than folded into a shared spec type.
+type tieredPolicy struct {
+	tier           string
+	name           string
+	order          *float64
+	ingress        []api.Rule
+	egress         []api.Rule
+	preDNAT        bool
+	doNotTrack     bool
+	applyOnForward bool
+}
+
+// Analyze walks ps's tiers in Spec.Order, and within each tier its policies in Spec.Order, looking
+// for the first rule that matches tuple. It stops at the first Allow or Deny, short-circuits a
+// Pass to the next tier, and falls back to Default if nothing in any tier matched.
+func Analyze(ps PolicySet, tuple Tuple) (*Verdict, error) {
+	byTier := map[string][]tieredPolicy{}
+	for _, gnp := range ps.GlobalNetworkPolicies {
+		t := gnp.Spec.Tier
+		if t == "" {
+			t = "default"
+		}
+		byTier[t] = append(byTier[t], tieredPolicy{
+			tier: t, name: gnp.Name, order: gnp.Spec.Order,
+			ingress: gnp.Spec.Ingress, egress: gnp.Spec.Egress,
+			preDNAT: gnp.Spec.PreDNAT, doNotTrack: gnp.Spec.DoNotTrack, applyOnForward: gnp.Spec.ApplyOnForward,
+		})
+	}
+	// A staged Set or Delete stands in for the enforced policy of the same name.
+	staged := map[string]bool{}
+	for _, s := range ps.StagedNetworkPolicies {
+		if s.Spec.StagedAction == api.StagedActionSet || s.Spec.StagedAction == api.StagedActionDelete {
+			staged[s.Namespace+"/"+s.Name] = true
+		}
+	}
+	for _, np := range ps.NetworkPolicies {
+		if staged[np.Namespace+"/"+np.Name] {
+			continue
+		}
+		t := np.Spec.Tier
+		if t == "" {
+			t = "default"
+		}
+		byTier[t] = append(byTier[t], tieredPolicy{
+			tier: t, name: np.Namespace + "/" + np.Name, order: np.Spec.Order,
+			ingress: np.Spec.Ingress, egress: np.Spec.Egress,
+		})
+	}
+	for _, staged := range ps.StagedNetworkPolicies {
+		if staged.Spec.StagedAction == api.StagedActionDelete {
+			continue
+		}
+		_, enforced := api.ConvertStagedPolicyToEnforced(&staged)
+		t := enforced.Spec.Tier
+		if t == "" {
+			t = "default"
+		}
+		byTier[t] = append(byTier[t], tieredPolicy{
+			tier: t, name: enforced.Namespace + "/" + enforced.Name, order: enforced.Spec.Order,
+			ingress: enforced.Spec.Ingress, egress: enforced.Spec.Egress,
+		})
+	}
+
+	tiers := make([]api.Tier, len(ps.Tiers))
+	copy(tiers, ps.Tiers)
+	sort.SliceStable(tiers, func(i, j int) bool {
+		return orderLess(tiers[i].Spec.Order, tiers[j].Spec.Order)
+	})
+
+	var matches []MatchedRule
+	for _, tier := range tiers {
+		policies := byTier[tier.Name]
+		sort.SliceStable(policies, func(i, j int) bool {
+			return orderLess(policies[i].order, policies[j].order)
+		})
+
+		tierAction, tierMatches, err := evaluateTier(policies, tuple)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, tierMatches...)
+
+		switch tierAction {
+		case api.Allow, api.Deny:
+			return &Verdict{Action: tierAction, Matches: matches}, nil
+		case api.Pass:
+			continue
+		}
+	}
+
+	return &Verdict{Action: Default, Matches: matches}, nil
+}
+
+// evaluateTier walks policies in order, returning the first Allow/Deny/Pass a rule produces, or
+// an empty action if nothing in the tier matched the tuple at all.
+func evaluateTier(policies []tieredPolicy, tuple Tuple) (api.Action, []MatchedRule, error) {
+	var matches []MatchedRule
+	for _, p := range policies {
+		if (p.preDNAT || p.doNotTrack) && !p.applyOnForward {
+			// ApplyOnForward is required for PreDNAT/DoNotTrack policies; without it the
+			// policy never applies to this (non-forwarded) dry-run tuple.
+			continue
+		}
+
+		rules := p.ingress
+		if tuple.Direction == api.PolicyTypeEgress {
+			rules = p.egress
+		}
+		if p.preDNAT && tuple.Direction == api.PolicyTypeEgress {
+			// PreDNAT policies may not have egress rules; validated elsewhere, but guard here too.
+			continue
+		}
+
+		for i, rule := range rules {
+			clause, ok, err := ruleMatches(rule, tuple)
+			if err != nil {
+				return "", matches, err
+			}
+			if !ok {
+				continue
+			}
+			matches = append(matches, MatchedRule{
+				Tier: p.tier, Policy: p.name, RuleIndex: i, Action: rule.Action, Clause: clause,
+			})
+			return rule.Action, matches, nil
+		}
+	}
+	return "", matches, nil
+}
+
+// ruleMatches reports whether rule matches tuple. For a rule that doesn't match, the clause names
+// the check that failed; for a rule that matches, it is always ClauseSelector, whichever clauses
+// the rule has.
+func ruleMatches(rule api.Rule, tuple Tuple) (Clause, bool, error) {
+	if rule.Protocol != nil && (tuple.Protocol == nil || *rule.Protocol != *tuple.Protocol) {
+		return ClauseProtocol, false, nil
+	}
+
+	if ok, clause, err := entityMatches(rule.Source, tuple.Source, tuple.Port); !ok || err != nil {
+		return clause, false, err
+	}
+	if ok, clause, err := entityMatches(rule.Destination, tuple.Destination, tuple.Port); !ok || err != nil {
+		return clause, false, err
+	}
+
+	return ClauseSelector, true, nil
+}
+
+// entityMatches evaluates one EntityRule (Source or Destination) against ep, returning the clause
+// that failed (if any) for the caller to report.
+func entityMatches(entity api.EntityRule, ep Endpoint, port *numorstring.Port) (bool, Clause, error) {
+	if entity.Selector != "" {
+		sel, err := selector.Parse(entity.Selector)
+		if err != nil {
+			return false, ClauseSelector, fmt.Errorf("invalid selector %q: %w", entity.Selector, err)
+		}
+		if !sel.Evaluate(ep.Labels) {
+			return false, ClauseSelector, nil
+		}
+	}
+
+	if entity.NamespaceSelector != "" {
+		sel, err := selector.Parse(entity.NamespaceSelector)
+		if err != nil {
+			return false, ClauseNamespaceSelector, fmt.Errorf("invalid namespaceSelector %q: %w", entity.NamespaceSelector, err)
+		}
+		if !sel.Evaluate(map[string]string{"projectcalico.org/name": ep.Namespace}) {
+			return false, ClauseNamespaceSelector, nil
+		}
+	}
+
+	if len(entity.Nets) > 0 && !cidrsContain(entity.Nets, ep.IP) {
+		return false, ClauseNets, nil
+	}
+	if len(entity.NotNets) > 0 && cidrsContain(entity.NotNets, ep.IP) {
+		return false, ClauseNotNets, nil
+	}
+
+	if sa := entity.ServiceAccounts; sa != nil {
+		if sa.Selector != "" {
+			return false, ClauseServiceAccounts, fmt.Errorf("entity rule's serviceAccounts.selector requires service account labels, which aren't available to offline policy analysis")
+		}
+		if len(sa.Names) > 0 && !stringSliceContains(sa.Names, ep.ServiceAccount) {
+			return false, ClauseServiceAccounts, nil
+		}
+	}
+
+	if entity.Services != nil {
+		return false, ClauseServices, fmt.Errorf("entity rule's services clause requires resolving Kubernetes Service membership, which isn't available to offline policy analysis")
+	}
+
+	if len(entity.Ports) > 0 && !portMatchesAny(entity.Ports, port) {
+		return false, ClausePorts, nil
+	}
+	if len(entity.NotPorts) > 0 && portMatchesAny(entity.NotPorts, port) {
+		return false, ClauseNotPorts, nil
+	}
+
+	return true, "", nil
+}
+
+func portMatchesAny(ports []numorstring.Port, port *numorstring.Port) bool {
+	if port == nil {
+		return false
+	}
+	for _, p := range ports {
+		if port.MinPort >= p.MinPort && port.MaxPort <= p.MaxPort {
+			return true
+		}
+	}
+	return false
+}
+
+// cidrsContain reports whether ip falls within any of cidrs. An unparseable ip or CIDR is treated
+// as not matching rather than as an error, consistent with how an out-of-range port is treated by
+// portMatchesAny.
+func cidrsContain(cidrs []string, ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, c := range cidrs {
+		_, ipNet, err := net.ParseCIDR(c)
+		if err != nil {
+			continue
+		}
+		if ipNet.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+func stringSliceContains(haystack []string, needle string) bool {
+	for _, s := range haystack {
+		if s == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// orderLess implements the nil-sorts-last ordering Felix uses for Spec.Order: unset orders run
+// after every explicitly ordered tier/policy, ties break by comparing the float values.
+func orderLess(a, b *float64) bool {
+	if a == nil {
+		return false
+	}
+	if b == nil {
+		return true
+	}
+	return *a < *b
+}
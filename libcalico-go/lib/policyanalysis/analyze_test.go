@@ -0,0 +1,166 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policyanalysis
+
+import (
+	"testing"
+
+	api "github.com/projectcalico/api/pkg/apis/projectcalico/v3"
+	"github.com/projectcalico/api/pkg/lib/numorstring"
+)
+
+func TestEntityMatchesNets(t *testing.T) {
+	rule := api.EntityRule{Nets: []string{"10.0.0.0/24"}}
+	ep := Endpoint{IP: "10.0.0.5"}
+
+	ok, _, err := entityMatches(rule, ep, nil)
+	if err != nil {
+		t.Fatalf("entityMatches() returned an error: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected an IP inside Nets to match")
+	}
+
+	ep.IP = "10.0.1.5"
+	ok, clause, err := entityMatches(rule, ep, nil)
+	if err != nil {
+		t.Fatalf("entityMatches() returned an error: %v", err)
+	}
+	if ok {
+		t.Fatal("expected an IP outside Nets not to match")
+	}
+	if clause != ClauseNets {
+		t.Errorf("clause = %q, want %q", clause, ClauseNets)
+	}
+}
+
+func TestEntityMatchesNotNets(t *testing.T) {
+	rule := api.EntityRule{NotNets: []string{"10.0.0.0/24"}}
+
+	ok, _, err := entityMatches(rule, Endpoint{IP: "192.168.1.1"}, nil)
+	if err != nil {
+		t.Fatalf("entityMatches() returned an error: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected an IP outside NotNets to match")
+	}
+
+	ok, clause, err := entityMatches(rule, Endpoint{IP: "10.0.0.5"}, nil)
+	if err != nil {
+		t.Fatalf("entityMatches() returned an error: %v", err)
+	}
+	if ok {
+		t.Fatal("expected an IP inside NotNets not to match")
+	}
+	if clause != ClauseNotNets {
+		t.Errorf("clause = %q, want %q", clause, ClauseNotNets)
+	}
+}
+
+func TestEntityMatchesServiceAccounts(t *testing.T) {
+	rule := api.EntityRule{ServiceAccounts: &api.ServiceAccountMatch{Names: []string{"allowed-sa"}}}
+
+	ok, _, err := entityMatches(rule, Endpoint{ServiceAccount: "allowed-sa"}, nil)
+	if err != nil {
+		t.Fatalf("entityMatches() returned an error: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected a listed ServiceAccount to match")
+	}
+
+	ok, clause, err := entityMatches(rule, Endpoint{ServiceAccount: "other-sa"}, nil)
+	if err != nil {
+		t.Fatalf("entityMatches() returned an error: %v", err)
+	}
+	if ok {
+		t.Fatal("expected an unlisted ServiceAccount not to match")
+	}
+	if clause != ClauseServiceAccounts {
+		t.Errorf("clause = %q, want %q", clause, ClauseServiceAccounts)
+	}
+}
+
+func TestEntityMatchesServiceAccountsSelectorUnsupported(t *testing.T) {
+	rule := api.EntityRule{ServiceAccounts: &api.ServiceAccountMatch{Selector: "role == 'db'"}}
+
+	_, clause, err := entityMatches(rule, Endpoint{}, nil)
+	if err == nil {
+		t.Fatal("expected an error for a serviceAccounts.selector clause")
+	}
+	if clause != ClauseServiceAccounts {
+		t.Errorf("clause = %q, want %q", clause, ClauseServiceAccounts)
+	}
+}
+
+func TestEntityMatchesServicesUnsupported(t *testing.T) {
+	rule := api.EntityRule{Services: &api.ServiceMatch{Name: "backend"}}
+
+	_, clause, err := entityMatches(rule, Endpoint{}, nil)
+	if err == nil {
+		t.Fatal("expected an error for a services clause")
+	}
+	if clause != ClauseServices {
+		t.Errorf("clause = %q, want %q", clause, ClauseServices)
+	}
+}
+
+func TestEntityMatchesNotPorts(t *testing.T) {
+	notPort, err := numorstring.PortFromRange(80, 80)
+	if err != nil {
+		t.Fatalf("building NotPorts: %v", err)
+	}
+	rule := api.EntityRule{NotPorts: []numorstring.Port{notPort}}
+
+	allowedPort, err := numorstring.PortFromRange(443, 443)
+	if err != nil {
+		t.Fatalf("building test port: %v", err)
+	}
+	ok, _, err := entityMatches(rule, Endpoint{}, &allowedPort)
+	if err != nil {
+		t.Fatalf("entityMatches() returned an error: %v", err)
+	}
+	if !ok {
+		t.Fatal("expected a port outside NotPorts to match")
+	}
+
+	blockedPort, err := numorstring.PortFromRange(80, 80)
+	if err != nil {
+		t.Fatalf("building test port: %v", err)
+	}
+	ok, clause, err := entityMatches(rule, Endpoint{}, &blockedPort)
+	if err != nil {
+		t.Fatalf("entityMatches() returned an error: %v", err)
+	}
+	if ok {
+		t.Fatal("expected a port inside NotPorts not to match")
+	}
+	if clause != ClauseNotPorts {
+		t.Errorf("clause = %q, want %q", clause, ClauseNotPorts)
+	}
+}
+
+func TestCidrsContain(t *testing.T) {
+	cidrs := []string{"10.0.0.0/24", "192.168.0.0/16"}
+
+	if !cidrsContain(cidrs, "192.168.5.5") {
+		t.Error("expected 192.168.5.5 to be contained")
+	}
+	if cidrsContain(cidrs, "172.16.0.1") {
+		t.Error("expected 172.16.0.1 not to be contained")
+	}
+	if cidrsContain(cidrs, "not-an-ip") {
+		t.Error("expected an unparseable IP not to be contained")
+	}
+}
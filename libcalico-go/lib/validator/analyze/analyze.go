@@ -0,0 +1,335 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package analyze looks for semantic problems across a batch of already-syntactically-valid
+// policies that no single-object validator in v3 can see: rules made dead by an earlier rule in
+// the same list, policies at the same tier order that disagree on Allow/Deny, selectors that
+// match nothing in a supplied endpoint inventory, and staged policies that would invert an
+// existing enforced policy's behavior. Unlike v3.Validate, a non-empty result here is a warning,
+// not a rejection -- callers like calicoctl or the apiserver surface it alongside admission rather
+// than failing on it.
+package analyze
+
+import (
+	"fmt"
+
+	api "github.com/projectcalico/api/pkg/apis/projectcalico/v3"
+
+	"github.com/projectcalico/calico/libcalico-go/lib/selector"
+)
+
+// Kind classifies what kind of problem an AnalysisResult reports.
+type Kind string
+
+const (
+	KindUnreachableRule       Kind = "UnreachableRule"
+	KindAllowDenyConflict     Kind = "AllowDenyConflict"
+	KindNoMatchingEndpoints   Kind = "NoMatchingEndpoints"
+	KindStagedInvertsEnforced Kind = "StagedInvertsEnforced"
+)
+
+// PolicyRef identifies the policy an AnalysisResult is about; the rule within it, for results
+// about a single rule, is carried separately in AnalysisResult.RuleIndex.
+type PolicyRef struct {
+	Tier      string
+	Namespace string // empty for GlobalNetworkPolicy
+	Name      string
+}
+
+func (r PolicyRef) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s/%s", r.Tier, r.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", r.Tier, r.Namespace, r.Name)
+}
+
+// AnalysisResult is one diagnostic the analyzer found.
+type AnalysisResult struct {
+	PolicyRef   PolicyRef
+	RuleIndex   int // -1 when the diagnostic isn't about a specific rule
+	Kind        Kind
+	Explanation string
+}
+
+// Endpoint is the subset of a WorkloadEndpoint/HostEndpoint's identity the analyzer needs to
+// decide whether a selector matches anything in the supplied inventory.
+type Endpoint struct {
+	Labels    map[string]string
+	Namespace string
+}
+
+// policy is every (Global)NetworkPolicy/StagedNetworkPolicy source normalized to what the
+// analyzer needs, so all four can be walked by the same code.
+type policy struct {
+	ref      PolicyRef
+	order    *float64
+	selector string
+	ingress  []api.Rule
+	egress   []api.Rule
+	staged   bool
+}
+
+// Batch is the snapshot of policy and endpoint state to analyze together.
+type Batch struct {
+	Tiers                       []api.Tier
+	GlobalNetworkPolicies       []api.GlobalNetworkPolicy
+	NetworkPolicies             []api.NetworkPolicy
+	StagedGlobalNetworkPolicies []api.StagedGlobalNetworkPolicy
+	StagedNetworkPolicies       []api.StagedNetworkPolicy
+	Endpoints                   []Endpoint
+}
+
+// Analyze runs every check in this package over batch and returns their combined results, in no
+// particular order.
+func Analyze(batch Batch) []AnalysisResult {
+	var results []AnalysisResult
+
+	policies := normalize(batch)
+
+	results = append(results, findUnreachableRules(policies)...)
+	results = append(results, findAllowDenyConflicts(policies)...)
+	results = append(results, findNoMatchingEndpoints(policies, batch.Endpoints)...)
+	results = append(results, findStagedInversions(batch)...)
+
+	return results
+}
+
+func normalize(batch Batch) []policy {
+	var out []policy
+	tierOf := func(t string) string {
+		if t == "" {
+			return "default"
+		}
+		return t
+	}
+
+	for _, gnp := range batch.GlobalNetworkPolicies {
+		out = append(out, policy{
+			ref:      PolicyRef{Tier: tierOf(gnp.Spec.Tier), Name: gnp.Name},
+			order:    gnp.Spec.Order,
+			selector: gnp.Spec.Selector,
+			ingress:  gnp.Spec.Ingress,
+			egress:   gnp.Spec.Egress,
+		})
+	}
+	for _, np := range batch.NetworkPolicies {
+		out = append(out, policy{
+			ref:      PolicyRef{Tier: tierOf(np.Spec.Tier), Namespace: np.Namespace, Name: np.Name},
+			order:    np.Spec.Order,
+			selector: np.Spec.Selector,
+			ingress:  np.Spec.Ingress,
+			egress:   np.Spec.Egress,
+		})
+	}
+	for _, staged := range batch.StagedNetworkPolicies {
+		if staged.Spec.StagedAction == api.StagedActionDelete {
+			continue
+		}
+		_, enforced := api.ConvertStagedPolicyToEnforced(&staged)
+		out = append(out, policy{
+			ref:      PolicyRef{Tier: tierOf(enforced.Spec.Tier), Namespace: enforced.Namespace, Name: enforced.Name},
+			order:    enforced.Spec.Order,
+			selector: enforced.Spec.Selector,
+			ingress:  enforced.Spec.Ingress,
+			egress:   enforced.Spec.Egress,
+			staged:   true,
+		})
+	}
+	for _, staged := range batch.StagedGlobalNetworkPolicies {
+		if staged.Spec.StagedAction == api.StagedActionDelete {
+			continue
+		}
+		out = append(out, policy{
+			ref:      PolicyRef{Tier: tierOf(staged.Spec.Tier), Name: staged.Name},
+			order:    staged.Spec.Order,
+			selector: staged.Spec.Selector,
+			ingress:  staged.Spec.Ingress,
+			egress:   staged.Spec.Egress,
+			staged:   true,
+		})
+	}
+
+	return out
+}
+
+// ruleIsCatchAll reports whether rule has no match criteria at all, i.e. it matches every flow in
+// its direction.
+func ruleIsCatchAll(rule api.Rule) bool {
+	return rule.Protocol == nil && rule.NotProtocol == nil && rule.ICMP == nil &&
+		rule.Source.Selector == "" && rule.Source.NamespaceSelector == "" && rule.Source.Services == nil &&
+		len(rule.Source.Nets) == 0 && len(rule.Source.NotNets) == 0 && len(rule.Source.Ports) == 0 &&
+		rule.Destination.Selector == "" && rule.Destination.NamespaceSelector == "" && rule.Destination.Services == nil &&
+		len(rule.Destination.Nets) == 0 && len(rule.Destination.NotNets) == 0 && len(rule.Destination.Ports) == 0
+}
+
+// findUnreachableRules flags every rule that follows a catch-all rule in the same rule list: once
+// a rule with no match criteria fires, nothing after it in that list can ever be reached.
+func findUnreachableRules(policies []policy) []AnalysisResult {
+	var results []AnalysisResult
+	check := func(ref PolicyRef, rules []api.Rule) {
+		caughtAllAt := -1
+		for i, rule := range rules {
+			if caughtAllAt >= 0 {
+				results = append(results, AnalysisResult{
+					PolicyRef: ref, RuleIndex: i, Kind: KindUnreachableRule,
+					Explanation: fmt.Sprintf("rule %d can never match: rule %d already matches every flow in this direction", i, caughtAllAt),
+				})
+				continue
+			}
+			if ruleIsCatchAll(rule) {
+				caughtAllAt = i
+			}
+		}
+	}
+	for _, p := range policies {
+		check(p.ref, p.ingress)
+		check(p.ref, p.egress)
+	}
+	return results
+}
+
+// findAllowDenyConflicts flags pairs of policies in the same tier, at the same explicit Order,
+// whose selectors are identical but whose first egress catch-all rules disagree on Allow vs Deny
+// (ingress rules are not compared) --
+// since Felix doesn't define a tie-break for same-order policies, such a pair's effective
+// behavior depends on a resolution order the author didn't control.
+func findAllowDenyConflicts(policies []policy) []AnalysisResult {
+	var results []AnalysisResult
+	for i, a := range policies {
+		if a.order == nil {
+			continue
+		}
+		for _, b := range policies[i+1:] {
+			if b.order == nil || b.ref.Tier != a.ref.Tier || *b.order != *a.order || b.selector != a.selector {
+				continue
+			}
+			aAction, aOK := catchAllAction(a.egress)
+			bAction, bOK := catchAllAction(b.egress)
+			if aOK && bOK && aAction != bAction {
+				results = append(results, AnalysisResult{
+					PolicyRef: a.ref, RuleIndex: -1, Kind: KindAllowDenyConflict,
+					Explanation: fmt.Sprintf("conflicts with %s: both apply to selector %q at tier order %v but disagree on egress Allow/Deny",
+						b.ref, a.selector, *a.order),
+				})
+			}
+		}
+	}
+	return results
+}
+
+func catchAllAction(rules []api.Rule) (api.Action, bool) {
+	for _, rule := range rules {
+		if ruleIsCatchAll(rule) {
+			return rule.Action, true
+		}
+	}
+	return "", false
+}
+
+// findNoMatchingEndpoints flags every policy whose Selector matches none of the supplied
+// endpoints. An empty inventory means "unknown", not "nothing matches", so it's skipped.
+func findNoMatchingEndpoints(policies []policy, endpoints []Endpoint) []AnalysisResult {
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	var results []AnalysisResult
+	for _, p := range policies {
+		if p.selector == "" {
+			continue
+		}
+		sel, err := selector.Parse(p.selector)
+		if err != nil {
+			// Malformed selectors are v3.Validate's job to catch; skip rather than double-report.
+			continue
+		}
+		matched := false
+		for _, ep := range endpoints {
+			if sel.Evaluate(ep.Labels) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			results = append(results, AnalysisResult{
+				PolicyRef: p.ref, RuleIndex: -1, Kind: KindNoMatchingEndpoints,
+				Explanation: fmt.Sprintf("selector %q matches none of the %d endpoints in the supplied inventory", p.selector, len(endpoints)),
+			})
+		}
+	}
+	return results
+}
+
+// findStagedInversions flags a staged policy whose enforced form's first rule has the opposite
+// Action from the first rule of an already-enforced policy of the same name/namespace/tier --
+// the common "accidentally flip Allow to Deny" staging mistake this feature exists to catch.
+func findStagedInversions(batch Batch) []AnalysisResult {
+	enforced := map[PolicyRef]policy{}
+	for _, gnp := range batch.GlobalNetworkPolicies {
+		ref := PolicyRef{Tier: tierOrDefault(gnp.Spec.Tier), Name: gnp.Name}
+		enforced[ref] = policy{ingress: gnp.Spec.Ingress, egress: gnp.Spec.Egress}
+	}
+	for _, np := range batch.NetworkPolicies {
+		ref := PolicyRef{Tier: tierOrDefault(np.Spec.Tier), Namespace: np.Namespace, Name: np.Name}
+		enforced[ref] = policy{ingress: np.Spec.Ingress, egress: np.Spec.Egress}
+	}
+
+	var results []AnalysisResult
+	checkStaged := func(ref PolicyRef, ingress, egress []api.Rule) {
+		existing, ok := enforced[ref]
+		if !ok {
+			return
+		}
+		if inverts(existing.ingress, ingress) || inverts(existing.egress, egress) {
+			results = append(results, AnalysisResult{
+				PolicyRef: ref, RuleIndex: 0, Kind: KindStagedInvertsEnforced,
+				Explanation: "staged policy's first rule action is the opposite of the currently enforced policy's first rule",
+			})
+		}
+	}
+
+	for _, staged := range batch.StagedGlobalNetworkPolicies {
+		if staged.Spec.StagedAction == api.StagedActionDelete {
+			continue
+		}
+		ref := PolicyRef{Tier: tierOrDefault(staged.Spec.Tier), Name: staged.Name}
+		checkStaged(ref, staged.Spec.Ingress, staged.Spec.Egress)
+	}
+	for _, staged := range batch.StagedNetworkPolicies {
+		if staged.Spec.StagedAction == api.StagedActionDelete {
+			continue
+		}
+		_, stagedEnforced := api.ConvertStagedPolicyToEnforced(&staged)
+		ref := PolicyRef{Tier: tierOrDefault(stagedEnforced.Spec.Tier), Namespace: stagedEnforced.Namespace, Name: stagedEnforced.Name}
+		checkStaged(ref, stagedEnforced.Spec.Ingress, stagedEnforced.Spec.Egress)
+	}
+
+	return results
+}
+
+func inverts(existing, staged []api.Rule) bool {
+	if len(existing) == 0 || len(staged) == 0 {
+		return false
+	}
+	return existing[0].Action != staged[0].Action &&
+		(existing[0].Action == api.Allow || existing[0].Action == api.Deny) &&
+		(staged[0].Action == api.Allow || staged[0].Action == api.Deny)
+}
+
+func tierOrDefault(t string) string {
+	if t == "" {
+		return "default"
+	}
+	return t
+}
@@ -0,0 +1,173 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package analyze
+
+import (
+	"testing"
+
+	api "github.com/projectcalico/api/pkg/apis/projectcalico/v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func float64Ptr(f float64) *float64 { return &f }
+
+func TestFindUnreachableRules(t *testing.T) {
+	batch := Batch{
+		GlobalNetworkPolicies: []api.GlobalNetworkPolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "catch-all-first"},
+			Spec: api.GlobalNetworkPolicySpec{
+				Ingress: []api.Rule{
+					{Action: api.Allow},
+					{Action: api.Deny, Source: api.EntityRule{Selector: "role == 'db'"}},
+				},
+			},
+		}},
+	}
+
+	results := Analyze(batch)
+	if !containsKind(results, KindUnreachableRule) {
+		t.Fatalf("expected an UnreachableRule result, got %+v", results)
+	}
+	for _, r := range results {
+		if r.Kind == KindUnreachableRule && r.RuleIndex != 1 {
+			t.Errorf("expected the unreachable rule to be index 1, got %d", r.RuleIndex)
+		}
+	}
+}
+
+func TestFindUnreachableRulesNoCatchAll(t *testing.T) {
+	batch := Batch{
+		GlobalNetworkPolicies: []api.GlobalNetworkPolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "no-catch-all"},
+			Spec: api.GlobalNetworkPolicySpec{
+				Ingress: []api.Rule{
+					{Action: api.Allow, Source: api.EntityRule{Selector: "role == 'web'"}},
+					{Action: api.Deny, Source: api.EntityRule{Selector: "role == 'db'"}},
+				},
+			},
+		}},
+	}
+
+	if results := Analyze(batch); containsKind(results, KindUnreachableRule) {
+		t.Fatalf("expected no UnreachableRule result, got %+v", results)
+	}
+}
+
+func TestFindAllowDenyConflicts(t *testing.T) {
+	batch := Batch{
+		GlobalNetworkPolicies: []api.GlobalNetworkPolicy{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "allow-web"},
+				Spec: api.GlobalNetworkPolicySpec{
+					Order:    float64Ptr(10),
+					Selector: "role == 'web'",
+					Egress:   []api.Rule{{Action: api.Allow}},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "deny-web"},
+				Spec: api.GlobalNetworkPolicySpec{
+					Order:    float64Ptr(10),
+					Selector: "role == 'web'",
+					Egress:   []api.Rule{{Action: api.Deny}},
+				},
+			},
+		},
+	}
+
+	results := Analyze(batch)
+	if !containsKind(results, KindAllowDenyConflict) {
+		t.Fatalf("expected an AllowDenyConflict result, got %+v", results)
+	}
+}
+
+func TestFindAllowDenyConflictsDifferentOrderNotFlagged(t *testing.T) {
+	batch := Batch{
+		GlobalNetworkPolicies: []api.GlobalNetworkPolicy{
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "allow-web"},
+				Spec: api.GlobalNetworkPolicySpec{
+					Order:    float64Ptr(10),
+					Selector: "role == 'web'",
+					Egress:   []api.Rule{{Action: api.Allow}},
+				},
+			},
+			{
+				ObjectMeta: metav1.ObjectMeta{Name: "deny-web"},
+				Spec: api.GlobalNetworkPolicySpec{
+					Order:    float64Ptr(20),
+					Selector: "role == 'web'",
+					Egress:   []api.Rule{{Action: api.Deny}},
+				},
+			},
+		},
+	}
+
+	if results := Analyze(batch); containsKind(results, KindAllowDenyConflict) {
+		t.Fatalf("expected no AllowDenyConflict result, got %+v", results)
+	}
+}
+
+func TestFindNoMatchingEndpoints(t *testing.T) {
+	batch := Batch{
+		GlobalNetworkPolicies: []api.GlobalNetworkPolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "orphaned"},
+			Spec:       api.GlobalNetworkPolicySpec{Selector: "role == 'ghost'"},
+		}},
+		Endpoints: []Endpoint{{Labels: map[string]string{"role": "web"}}},
+	}
+
+	results := Analyze(batch)
+	if !containsKind(results, KindNoMatchingEndpoints) {
+		t.Fatalf("expected a NoMatchingEndpoints result, got %+v", results)
+	}
+}
+
+func TestFindNoMatchingEndpointsEmptyInventorySkipped(t *testing.T) {
+	batch := Batch{
+		GlobalNetworkPolicies: []api.GlobalNetworkPolicy{{
+			ObjectMeta: metav1.ObjectMeta{Name: "orphaned"},
+			Spec:       api.GlobalNetworkPolicySpec{Selector: "role == 'ghost'"},
+		}},
+	}
+
+	if results := Analyze(batch); containsKind(results, KindNoMatchingEndpoints) {
+		t.Fatalf("expected an empty inventory to be treated as unknown, not no-match, got %+v", results)
+	}
+}
+
+func TestInverts(t *testing.T) {
+	allow := []api.Rule{{Action: api.Allow}}
+	deny := []api.Rule{{Action: api.Deny}}
+
+	if !inverts(allow, deny) {
+		t.Error("expected Allow followed by Deny to be flagged as an inversion")
+	}
+	if inverts(allow, allow) {
+		t.Error("expected matching actions not to be flagged as an inversion")
+	}
+	if inverts(nil, deny) {
+		t.Error("expected no existing rules not to be flagged as an inversion")
+	}
+}
+
+func containsKind(results []AnalysisResult, kind Kind) bool {
+	for _, r := range results {
+		if r.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
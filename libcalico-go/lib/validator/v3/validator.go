@@ -17,6 +17,7 @@ package v3
 import (
 	"fmt"
 	"net"
+	"net/netip"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -29,6 +30,7 @@ import (
 	"gopkg.in/go-playground/validator.v9"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	k8svalidation "k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
 
 	libapi "github.com/projectcalico/calico/libcalico-go/lib/apis/v3"
 	calicoconversion "github.com/projectcalico/calico/libcalico-go/lib/backend/k8s/conversion"
@@ -38,10 +40,9 @@ import (
 	"github.com/projectcalico/calico/libcalico-go/lib/selector"
 	"github.com/projectcalico/calico/libcalico-go/lib/selector/tokenizer"
 	"github.com/projectcalico/calico/libcalico-go/lib/set"
+	"github.com/projectcalico/calico/libcalico-go/lib/validator/analyze"
 )
 
-var validate *validator.Validate
-
 const (
 	// Maximum size of annotations.
 	totalAnnotationSizeLimitB int64 = 256 * (1 << 10) // 256 kB
@@ -107,8 +108,16 @@ var (
 	standardCommunity       = regexp.MustCompile(`^(\d+):(\d+)$`)
 	largeCommunity          = regexp.MustCompile(`^(\d+):(\d+):(\d+)$`)
 	number                  = regexp.MustCompile(`(\d+)`)
-	IPv4PortFormat          = regexp.MustCompile(`^(\d+).(\d+).(\d+).(\d+):(\d+)$`)
-	IPv6PortFormat          = regexp.MustCompile(`^\[[0-9a-fA-F:.]+\]:(\d+)$`)
+
+	// extCommunityTwoOctetAS and extCommunityFourOctetAS match RFC 4360 AS-specific extended
+	// communities in their "rt:"/"soo:" textual encodings: two-octet AS (aa:nn, both plain
+	// integers) and four-octet AS (aa.bb:nn, AS in dotted notation).
+	extCommunityTwoOctetAS  = regexp.MustCompile(`^(rt|soo):(\d+):(\d+)$`)
+	extCommunityFourOctetAS = regexp.MustCompile(`^(rt|soo):(\d+)\.(\d+):(\d+)$`)
+	// extCommunityIPv4 matches the IPv4-address-specific form, "rt:A.B.C.D:nn".
+	extCommunityIPv4 = regexp.MustCompile(`^(rt|soo):(\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}):(\d+)$`)
+	// extCommunityOpaque matches the opaque form, "opaque:0xHHHHHHHHHHHH" (up to 48 bits of hex).
+	extCommunityOpaque = regexp.MustCompile(`^opaque:0x([0-9a-fA-F]+)$`)
 	reasonString            = "Reason: "
 	poolUnstictCIDR         = "IP pool CIDR is not strictly masked"
 	overlapsV4LinkLocal     = "IP pool range overlaps with IPv4 Link Local range 169.254.0.0/16"
@@ -124,6 +133,15 @@ var (
 	filterActionRegex  = regexp.MustCompile("^(Accept|Reject)$")
 	matchOperatorRegex = regexp.MustCompile("^(Equal|In|NotEqual|NotIn)$")
 
+	// nftIdentifierRegex matches nft's own identifier rules for chain, table and set names: a
+	// letter or underscore followed by up to 31 further letters, digits or underscores.
+	nftIdentifierRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]{0,31}$`)
+
+	// nftSetElementTypes are the scalar element types nft supports for a named set, per
+	// https://wiki.nftables.org/wiki-nftables/index.php/Sets -- the ones Felix's own rule
+	// generation could plausibly need.
+	nftSetElementTypes = set.FromArray([]string{"ipv4_addr", "ipv6_addr", "ether_addr", "inet_proto", "inet_service", "mark"})
+
 	ipv4LinkLocalNet = net.IPNet{
 		IP:   net.ParseIP("169.254.0.0"),
 		Mask: net.CIDRMask(16, 32),
@@ -141,139 +159,377 @@ var (
 		"|" + string(api.StagedActionLearn) + "|" + string(api.StagedActionIgnore) + ")$")
 )
 
-// Validate is used to validate the supplied structure according to the
-// registered field and structure validators.
-func Validate(current interface{}) error {
+// Validator wraps a go-playground validator.v9 instance together with the reason-prefix
+// convention used to tunnel a human-readable reason through a validator tag and recover it again
+// from the resulting validator.FieldError. The package-level Validate function is a thin wrapper
+// over a default instance built by registerDefaultValidators; projects embedding libcalico-go
+// that need extra tags or struct validators for their own types (enterprise builds, third-party
+// controllers/CRDs) can build their own instance with NewValidator instead of forking this file.
+type Validator struct {
+	validate     *validator.Validate
+	reasonPrefix string
+}
+
+// ValidatorOption configures a Validator constructed by NewValidator.
+type ValidatorOption func(*Validator)
+
+// WithExtraFieldValidator layers an additional field validator under tag onto a Validator built
+// by NewValidator, without touching this package's default instance.
+func WithExtraFieldValidator(tag string, fn validator.Func) ValidatorOption {
+	return func(v *Validator) {
+		v.RegisterField(tag, fn)
+	}
+}
+
+// WithReasonPrefix overrides the prefix this Validator tunnels a reason through when embedding
+// it in a tag and strips back off in Validate. It has no effect on this package's own field/
+// struct validators, which always embed their reason with the literal "Reason: " prefix -- it
+// exists so a caller layering their own validators via WithExtraFieldValidator/RegisterStruct can
+// pick a tunneling prefix of their own.
+func WithReasonPrefix(prefix string) ValidatorOption {
+	return func(v *Validator) {
+		v.reasonPrefix = prefix
+	}
+}
+
+// NewValidator builds a Validator carrying this package's full set of default field and struct
+// validators, then applies opts on top.
+func NewValidator(opts ...ValidatorOption) *Validator {
+	v := &Validator{
+		validate:     validator.New(),
+		reasonPrefix: reasonString,
+	}
+	registerDefaultValidators(v)
+	for _, opt := range opts {
+		opt(v)
+	}
+	return v
+}
+
+// RegisterField registers a field validator function under tag on this Validator instance only.
+func (v *Validator) RegisterField(tag string, fn validator.Func) {
+	v.validate.RegisterValidation(tag, fn)
+}
+
+// RegisterStruct registers a struct-level validator function for each of types on this Validator
+// instance only.
+func (v *Validator) RegisterStruct(fn validator.StructLevelFunc, types ...interface{}) {
+	v.validate.RegisterStructValidation(fn, types...)
+}
+
+// Validate validates current according to this Validator's registered field and structure
+// validators.
+func (v *Validator) Validate(current interface{}) error {
 	// Perform field-only validation first, that way the struct validators can assume
 	// individual fields are valid format.
-	if err := validate.Struct(current); err != nil {
-		return convertError(err)
+	if err := v.validate.Struct(current); err != nil {
+		return convertError(err, v.reasonPrefix)
 	}
 	return nil
 }
 
-func convertError(err error) errors.ErrorValidation {
+// defaultValidator is the instance backing the package-level Validate function, carrying exactly
+// the registrations in registerDefaultValidators and no caller-supplied options.
+var defaultValidator = NewValidator()
+
+// Validate is used to validate the supplied structure according to the
+// registered field and structure validators.
+func Validate(current interface{}) error {
+	return defaultValidator.Validate(current)
+}
+
+// AnalyzeDiagnostics runs the semantic policy analyzer in
+// github.com/projectcalico/calico/libcalico-go/lib/validator/analyze over batch, optionally
+// consulting batch.Endpoints so selectors matching nothing can be flagged. Unlike Validate, its
+// results are warnings, not rejections: a caller such as calicoctl or the apiserver is expected to
+// surface them alongside admission, not in place of it.
+func AnalyzeDiagnostics(batch analyze.Batch) []analyze.AnalysisResult {
+	return analyze.Analyze(batch)
+}
+
+func convertError(err error, reasonPrefix string) errors.ErrorValidation {
 	verr := errors.ErrorValidation{}
 	for _, f := range err.(validator.ValidationErrors) {
 		verr.ErroredFields = append(verr.ErroredFields,
 			errors.ErroredField{
 				Name:   f.StructField(),
 				Value:  f.Value(),
-				Reason: extractReason(f),
+				Reason: extractReason(f, reasonPrefix),
 			})
 	}
 	return verr
 }
 
-func init() {
-	// Initialise static data.
-	validate = validator.New()
+// ErrorCode classifies why a field failed validation, independent of its human-readable reason,
+// so that a client can switch on the failure kind without parsing prose.
+type ErrorCode string
+
+const (
+	ErrorCodeInvalidFormat ErrorCode = "InvalidFormat"
+	ErrorCodeConflict      ErrorCode = "Conflict"
+	ErrorCodeOutOfRange    ErrorCode = "OutOfRange"
+	ErrorCodeUnsupported   ErrorCode = "Unsupported"
+	ErrorCodeRequired      ErrorCode = "Required"
+)
+
+// FieldDiagnostic is a machine-readable counterpart to errors.ErroredField: alongside the Go
+// struct field name, it carries the field's JSON pointer path (RFC 6901) within the object passed
+// to Validate and a coarse ErrorCode, so clients like kubectl apply or an operator SDK controller
+// can point at the exact offending path instead of parsing the human-readable reason string.
+type FieldDiagnostic struct {
+	Name        string
+	JSONPointer string
+	Code        ErrorCode
+	Value       interface{}
+	Reason      string
+}
+
+// ValidateDiagnostics behaves like Validate, additionally returning a FieldDiagnostic per failed
+// field when validation fails.
+func (v *Validator) ValidateDiagnostics(current interface{}) ([]FieldDiagnostic, error) {
+	rawErr := v.validate.Struct(current)
+	if rawErr == nil {
+		return nil, nil
+	}
+	verrs, ok := rawErr.(validator.ValidationErrors)
+	if !ok {
+		return nil, rawErr
+	}
+	diags := make([]FieldDiagnostic, 0, len(verrs))
+	for _, f := range verrs {
+		reason := extractReason(f, v.reasonPrefix)
+		diags = append(diags, FieldDiagnostic{
+			Name:        f.StructField(),
+			JSONPointer: jsonPointerForNamespace(current, f.Namespace()),
+			Code:        classifyErrorCode(f, reason),
+			Value:       f.Value(),
+			Reason:      reason,
+		})
+	}
+	return diags, convertError(rawErr, v.reasonPrefix)
+}
+
+// ValidateDiagnostics runs ValidateDiagnostics against the package's default Validator.
+func ValidateDiagnostics(current interface{}) ([]FieldDiagnostic, error) {
+	return defaultValidator.ValidateDiagnostics(current)
+}
 
+// namespaceSegmentRegex splits a validator.FieldError Namespace() path segment, such as
+// "Ingress[3]", into its field name and optional slice/array index.
+var namespaceSegmentRegex = regexp.MustCompile(`^(\w+)(?:\[(\d+)\])?$`)
+
+// jsonPointerForNamespace translates a validator.FieldError's Namespace() -- a dot-separated path
+// of Go struct field names and indices, rooted at current's own type -- into an RFC 6901 JSON
+// pointer, by walking current's type alongside the namespace and substituting each field's own
+// `json:"..."` tag name.
+func jsonPointerForNamespace(current interface{}, namespace string) string {
+	t := reflect.TypeOf(current)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	segments := strings.Split(namespace, ".")
+	if len(segments) > 0 {
+		// The leading segment is the root type's own name, not a field.
+		segments = segments[1:]
+	}
+
+	var pointer strings.Builder
+	for _, seg := range segments {
+		m := namespaceSegmentRegex.FindStringSubmatch(seg)
+		if m == nil {
+			pointer.WriteString("/")
+			pointer.WriteString(seg)
+			continue
+		}
+		fieldName, index := m[1], m[2]
+
+		for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			t = t.Elem()
+		}
+
+		jsonName := fieldName
+		if t != nil && t.Kind() == reflect.Struct {
+			if field, ok := t.FieldByName(fieldName); ok {
+				if tag := field.Tag.Get("json"); tag != "" && tag != "-" {
+					if name := strings.Split(tag, ",")[0]; name != "" {
+						jsonName = name
+					}
+				}
+				t = field.Type
+			}
+		}
+
+		pointer.WriteString("/")
+		pointer.WriteString(jsonName)
+		if index != "" {
+			pointer.WriteString("/")
+			pointer.WriteString(index)
+		}
+	}
+	return pointer.String()
+}
+
+// classifyErrorCode maps a validator.FieldError to a coarse ErrorCode. Field-level validators are
+// classified by their own tag name; struct-level validators in this package tunnel their reason
+// through the tag itself (see reason()), so those fall back to a handful of recognisable verbs in
+// the extracted reason text, defaulting to ErrorCodeInvalidFormat.
+func classifyErrorCode(e validator.FieldError, extractedReason string) ErrorCode {
+	switch e.Tag() {
+	case "required":
+		return ErrorCodeRequired
+	case "mustBeNil", "mustBeFalse":
+		return ErrorCodeConflict
+	}
+
+	lower := strings.ToLower(extractedReason)
+	switch {
+	case strings.Contains(lower, "cannot be empty") || strings.Contains(lower, "must not be empty") ||
+		strings.Contains(lower, "must be empty") || strings.Contains(lower, "cannot specify"):
+		return ErrorCodeConflict
+	case strings.Contains(lower, "must be between") || strings.Contains(lower, "must be greater") ||
+		strings.Contains(lower, "must be less") || strings.Contains(lower, "greater than or equal"):
+		return ErrorCodeOutOfRange
+	case strings.Contains(lower, "not supported") || strings.Contains(lower, "unsupported") ||
+		strings.Contains(lower, "invalid community"):
+		return ErrorCodeUnsupported
+	default:
+		return ErrorCodeInvalidFormat
+	}
+}
+
+// ToStatus renders diags as a Kubernetes-style *metav1.Status, with one Causes entry per
+// FieldDiagnostic, so an apiserver-backed webhook or operator SDK controller can return them
+// directly as the validation failure response.
+func ToStatus(diags []FieldDiagnostic) *metav1.Status {
+	status := &metav1.Status{
+		Status:  metav1.StatusFailure,
+		Reason:  metav1.StatusReasonInvalid,
+		Message: "validation failed",
+		Details: &metav1.StatusDetails{},
+	}
+	for _, d := range diags {
+		status.Details.Causes = append(status.Details.Causes, metav1.StatusCause{
+			Type:    metav1.CauseTypeFieldValueInvalid,
+			Message: d.Reason,
+			Field:   d.JSONPointer,
+		})
+	}
+	return status
+}
+
+// registerDefaultValidators registers every field and struct validator this package ships with
+// against v. It's the sole body of what used to be this file's init(), now run once for the
+// package-level defaultValidator and again for every NewValidator call.
+func registerDefaultValidators(v *Validator) {
 	// Register field validators.
-	registerFieldValidator("action", validateAction)
-	registerFieldValidator("interface", validateInterface)
-	registerFieldValidator("bgpFilterInterface", validateBGPFilterInterface)
-	registerFieldValidator("bgpFilterPrefixLengthV4", validateBGPFilterPrefixLengthV4)
-	registerFieldValidator("bgpFilterPrefixLengthV6", validateBGPFilterPrefixLengthV6)
-	registerFieldValidator("ignoredInterface", validateIgnoredInterface)
-	registerFieldValidator("datastoreType", validateDatastoreType)
-	registerFieldValidator("name", validateName)
-	registerFieldValidator("containerID", validateContainerID)
-	registerFieldValidator("selector", validateSelector)
-	registerFieldValidator("labels", validateLabels)
-	registerFieldValidator("ipVersion", validateIPVersion)
-	registerFieldValidator("ipIpMode", validateIPIPMode)
-	registerFieldValidator("stagedAction", validateStagedAction)
-	registerFieldValidator("vxlanMode", validateVXLANMode)
-	registerFieldValidator("assignmentMode", validateAssignmentMode)
-	registerFieldValidator("assignIPs", validateAssignIPs)
-	registerFieldValidator("policyType", validatePolicyType)
-	registerFieldValidator("logLevel", validateLogLevel)
-	registerFieldValidator("bpfLogLevel", validateBPFLogLevel)
-	registerFieldValidator("bpfLogFilters", validateBPFLogFilters)
-	registerFieldValidator("bpfServiceMode", validateBPFServiceMode)
-	registerFieldValidator("bpfConnectTimeLoadBalancing", validateBPFConnectTimeLoadBalancing)
-	registerFieldValidator("bpfHostNetworkedNATWithoutCTLB", validateBPFHostNetworkedNat)
-	registerFieldValidator("dropAcceptReturn", validateFelixEtoHAction)
-	registerFieldValidator("acceptReturn", validateAcceptReturn)
-	registerFieldValidator("dropReject", validateDropReject)
-	registerFieldValidator("portName", validatePortName)
-	registerFieldValidator("mustBeNil", validateMustBeNil)
-	registerFieldValidator("mustBeFalse", validateMustBeFalse)
-	registerFieldValidator("ifaceFilter", validateIfaceFilter)
-	registerFieldValidator("interfaceSlice", validateInterfaceSlice)
-	registerFieldValidator("ifaceFilterSlice", validateIfaceFilterSlice)
-	registerFieldValidator("mac", validateMAC)
-	registerFieldValidator("iptablesBackend", validateIptablesBackend)
-	registerFieldValidator("keyValueList", validateKeyValueList)
-	registerFieldValidator("prometheusHost", validatePrometheusHost)
-	registerFieldValidator("ipType", validateIPType)
-	registerFieldValidator("createDefaultHostEndpoint", validateCreateDefaultHostEndpoint)
-
-	registerFieldValidator("sourceAddress", RegexValidator("SourceAddress", SourceAddressRegex))
-	registerFieldValidator("regexp", validateRegexp)
-	registerFieldValidator("routeSource", validateRouteSource)
-	registerFieldValidator("wireguardPublicKey", validateWireguardPublicKey)
-	registerFieldValidator("IP:port", validateIPPort)
-	registerFieldValidator("reachableBy", validateReachableByField)
+	v.RegisterField("action", validateAction)
+	v.RegisterField("interface", validateInterface)
+	v.RegisterField("bgpFilterInterface", validateBGPFilterInterface)
+	v.RegisterField("bgpFilterPrefixLengthV4", validateBGPFilterPrefixLengthV4)
+	v.RegisterField("bgpFilterPrefixLengthV6", validateBGPFilterPrefixLengthV6)
+	v.RegisterField("ignoredInterface", validateIgnoredInterface)
+	v.RegisterField("datastoreType", validateDatastoreType)
+	v.RegisterField("name", validateName)
+	v.RegisterField("containerID", validateContainerID)
+	v.RegisterField("selector", validateSelector)
+	v.RegisterField("labels", validateLabels)
+	v.RegisterField("ipVersion", validateIPVersion)
+	v.RegisterField("ipIpMode", validateIPIPMode)
+	v.RegisterField("stagedAction", validateStagedAction)
+	v.RegisterField("vxlanMode", validateVXLANMode)
+	v.RegisterField("assignmentMode", validateAssignmentMode)
+	v.RegisterField("assignIPs", validateAssignIPs)
+	v.RegisterField("policyType", validatePolicyType)
+	v.RegisterField("logLevel", validateLogLevel)
+	v.RegisterField("bpfLogLevel", validateBPFLogLevel)
+	v.RegisterField("bpfLogFilters", validateBPFLogFilters)
+	v.RegisterField("bpfServiceMode", validateBPFServiceMode)
+	v.RegisterField("bpfConnectTimeLoadBalancing", validateBPFConnectTimeLoadBalancing)
+	v.RegisterField("bpfHostNetworkedNATWithoutCTLB", validateBPFHostNetworkedNat)
+	v.RegisterField("dropAcceptReturn", validateFelixEtoHAction)
+	v.RegisterField("acceptReturn", validateAcceptReturn)
+	v.RegisterField("dropReject", validateDropReject)
+	v.RegisterField("portName", validatePortName)
+	v.RegisterField("mustBeNil", validateMustBeNil)
+	v.RegisterField("mustBeFalse", validateMustBeFalse)
+	v.RegisterField("ifaceFilter", validateIfaceFilter)
+	v.RegisterField("interfaceSlice", validateInterfaceSlice)
+	v.RegisterField("ifaceFilterSlice", validateIfaceFilterSlice)
+	v.RegisterField("mac", validateMAC)
+	v.RegisterField("iptablesBackend", validateIptablesBackend)
+	v.RegisterField("nftIdentifier", RegexValidator("NftIdentifier", nftIdentifierRegex))
+	v.RegisterField("nftSetElementType", validateNftSetElementType)
+	v.RegisterField("keyValueList", validateKeyValueList)
+	v.RegisterField("prometheusHost", validatePrometheusHost)
+	v.RegisterField("ipType", validateIPType)
+	v.RegisterField("createDefaultHostEndpoint", validateCreateDefaultHostEndpoint)
+
+	v.RegisterField("sourceAddress", RegexValidator("SourceAddress", SourceAddressRegex))
+	v.RegisterField("regexp", validateRegexp)
+	v.RegisterField("routeSource", validateRouteSource)
+	v.RegisterField("wireguardPublicKey", validateWireguardPublicKey)
+	v.RegisterField("IP:port", validateIPPort)
+	v.RegisterField("reachableBy", validateReachableByField)
 
 	// Register filter action and match operator validators (used in BGPFilter)
-	registerFieldValidator("filterAction", RegexValidator("FilterAction", filterActionRegex))
-	registerFieldValidator("matchOperator", RegexValidator("MatchOperator", matchOperatorRegex))
+	v.RegisterField("filterAction", RegexValidator("FilterAction", filterActionRegex))
+	v.RegisterField("matchOperator", RegexValidator("MatchOperator", matchOperatorRegex))
 
 	// Register filter action and match operator validators (used in BGPFilter)
-	registerFieldValidator("filterAction", RegexValidator("FilterAction", filterActionRegex))
-	registerFieldValidator("matchOperator", RegexValidator("MatchOperator", matchOperatorRegex))
+	v.RegisterField("filterAction", RegexValidator("FilterAction", filterActionRegex))
+	v.RegisterField("matchOperator", RegexValidator("MatchOperator", matchOperatorRegex))
 
 	// Register network validators (i.e. validating a correctly masked CIDR).  Also
-	// accepts an IP address without a mask (assumes a full mask).
-	registerFieldValidator("netv4", validateIPv4Network)
-	registerFieldValidator("netv6", validateIPv6Network)
-	registerFieldValidator("net", validateIPNetwork)
-	registerFieldValidator("ipv4", validateIPv4)
-	registerFieldValidator("ipv6", validateIPv6)
+	// accepts an IP address without a mask.
+	v.RegisterField("netv4", validateIPv4Network)
+	v.RegisterField("netv6", validateIPv6Network)
+	v.RegisterField("net", validateIPNetwork)
+	v.RegisterField("ipv4", validateIPv4)
+	v.RegisterField("ipv6", validateIPv6)
 
 	// Override the default CIDR validator.  Validates an arbitrary CIDR (does not
 	// need to be correctly masked).  Also accepts an IP address without a mask.
-	registerFieldValidator("cidrv4", validateCIDRv4)
-	registerFieldValidator("cidrv6", validateCIDRv6)
-	registerFieldValidator("cidr", validateCIDR)
-	registerFieldValidator("cidrs", validateCIDRs)
-
-	registerStructValidator(validate, validateProtocol, numorstring.Protocol{})
-	registerStructValidator(validate, validateProtoPort, api.ProtoPort{})
-	registerStructValidator(validate, validatePort, numorstring.Port{})
-	registerStructValidator(validate, validateEndpointPort, api.EndpointPort{})
-	registerStructValidator(validate, validateWorkloadEndpointPort, libapi.WorkloadEndpointPort{})
-	registerStructValidator(validate, validateIPNAT, libapi.IPNAT{})
-	registerStructValidator(validate, validateICMPFields, api.ICMPFields{})
-	registerStructValidator(validate, validateIPPoolSpec, api.IPPoolSpec{})
-	registerStructValidator(validate, validateNodeSpec, libapi.NodeSpec{})
-	registerStructValidator(validate, validateIPAMConfigSpec, libapi.IPAMConfigSpec{})
-	registerStructValidator(validate, validateObjectMeta, metav1.ObjectMeta{})
-	registerStructValidator(validate, validateTier, api.Tier{})
-	registerStructValidator(validate, validateHTTPRule, api.HTTPMatch{})
-	registerStructValidator(validate, validateFelixConfigSpec, api.FelixConfigurationSpec{})
-	registerStructValidator(validate, validateWorkloadEndpointSpec, libapi.WorkloadEndpointSpec{})
-	registerStructValidator(validate, validateHostEndpointSpec, api.HostEndpointSpec{})
-	registerStructValidator(validate, validateRule, api.Rule{})
-	registerStructValidator(validate, validateEntityRule, api.EntityRule{})
-	registerStructValidator(validate, validateBGPPeerSpec, api.BGPPeerSpec{})
-	registerStructValidator(validate, validateBGPFilterRuleV4, api.BGPFilterRuleV4{})
-	registerStructValidator(validate, validateBGPFilterRuleV6, api.BGPFilterRuleV6{})
-	registerStructValidator(validate, validateNetworkPolicy, api.NetworkPolicy{})
-	registerStructValidator(validate, validateGlobalNetworkPolicy, api.GlobalNetworkPolicy{})
-	registerStructValidator(validate, validateStagedGlobalNetworkPolicy, api.StagedGlobalNetworkPolicy{})
-	registerStructValidator(validate, validateStagedNetworkPolicy, api.StagedNetworkPolicy{})
-	registerStructValidator(validate, validateStagedKubernetesNetworkPolicy, api.StagedKubernetesNetworkPolicy{})
-	registerStructValidator(validate, validateGlobalNetworkSet, api.GlobalNetworkSet{})
-	registerStructValidator(validate, validateNetworkSet, api.NetworkSet{})
-	registerStructValidator(validate, validateRuleMetadata, api.RuleMetadata{})
-	registerStructValidator(validate, validateRouteTableIDRange, api.RouteTableIDRange{})
-	registerStructValidator(validate, validateRouteTableRange, api.RouteTableRange{})
-	registerStructValidator(validate, validateBGPConfigurationSpec, api.BGPConfigurationSpec{})
-	registerStructValidator(validate, validateBlockAffinitySpec, libapi.BlockAffinitySpec{})
-	registerStructValidator(validate, validateHealthTimeoutOverride, api.HealthTimeoutOverride{})
+	v.RegisterField("cidrv4", validateCIDRv4)
+	v.RegisterField("cidrv6", validateCIDRv6)
+	v.RegisterField("cidr", validateCIDR)
+	v.RegisterField("cidrs", validateCIDRs)
+
+	v.RegisterStruct(validateProtocol, numorstring.Protocol{})
+	v.RegisterStruct(validateProtoPort, api.ProtoPort{})
+	v.RegisterStruct(validatePort, numorstring.Port{})
+	v.RegisterStruct(validateEndpointPort, api.EndpointPort{})
+	v.RegisterStruct(validateWorkloadEndpointPort, libapi.WorkloadEndpointPort{})
+	v.RegisterStruct(validateIPNAT, libapi.IPNAT{})
+	v.RegisterStruct(validateICMPFields, api.ICMPFields{})
+	v.RegisterStruct(validateIPPoolSpec, api.IPPoolSpec{})
+	v.RegisterStruct(validateNodeSpec, libapi.NodeSpec{})
+	v.RegisterStruct(validateIPAMConfigSpec, libapi.IPAMConfigSpec{})
+	v.RegisterStruct(validateObjectMeta, metav1.ObjectMeta{})
+	v.RegisterStruct(validateTier, api.Tier{})
+	v.RegisterStruct(validateHTTPRule, api.HTTPMatch{})
+	v.RegisterStruct(validateFelixConfigSpec, api.FelixConfigurationSpec{})
+	v.RegisterStruct(validateNftablesConfig, NftablesConfigurationSpec{})
+	v.RegisterStruct(validateWorkloadEndpointSpec, libapi.WorkloadEndpointSpec{})
+	v.RegisterStruct(validateHostEndpointSpec, api.HostEndpointSpec{})
+	v.RegisterStruct(validateRule, api.Rule{})
+	v.RegisterStruct(validateEntityRule, api.EntityRule{})
+	v.RegisterStruct(validateBGPPeerSpec, api.BGPPeerSpec{})
+	v.RegisterStruct(validateBGPFilterRuleV4, api.BGPFilterRuleV4{})
+	v.RegisterStruct(validateBGPFilterRuleV6, api.BGPFilterRuleV6{})
+	v.RegisterStruct(validateNetworkPolicy, api.NetworkPolicy{})
+	v.RegisterStruct(validateGlobalNetworkPolicy, api.GlobalNetworkPolicy{})
+	v.RegisterStruct(validateStagedGlobalNetworkPolicy, api.StagedGlobalNetworkPolicy{})
+	v.RegisterStruct(validateStagedNetworkPolicy, api.StagedNetworkPolicy{})
+	v.RegisterStruct(validateStagedKubernetesNetworkPolicy, api.StagedKubernetesNetworkPolicy{})
+	v.RegisterStruct(validateGlobalNetworkSet, api.GlobalNetworkSet{})
+	v.RegisterStruct(validateNetworkSet, api.NetworkSet{})
+	v.RegisterStruct(validateRuleMetadata, api.RuleMetadata{})
+	v.RegisterStruct(validateRouteTableIDRange, api.RouteTableIDRange{})
+	v.RegisterStruct(validateRouteTableRange, api.RouteTableRange{})
+	v.RegisterStruct(validateBGPConfigurationSpec, api.BGPConfigurationSpec{})
+	v.RegisterStruct(validateBlockAffinitySpec, libapi.BlockAffinitySpec{})
+	v.RegisterStruct(validateHealthTimeoutOverride, api.HealthTimeoutOverride{})
 }
 
 // reason returns the provided error reason prefixed with an identifier that
@@ -283,29 +539,23 @@ func reason(r string) string {
 	return reasonString + r
 }
 
-// extractReason extracts the error reason from the field tag in a validator
-// field error (if there is one).
-func extractReason(e validator.FieldError) string {
+// extractReason extracts the error reason from the field tag in a validator field error (if
+// there is one), recognising reasons tunneled through the given prefix.
+func extractReason(e validator.FieldError, reasonPrefix string) string {
+	if strings.HasPrefix(e.Tag(), reasonPrefix) {
+		return strings.TrimPrefix(e.Tag(), reasonPrefix)
+	}
+	// This package's own validators always tunnel their reason through reasonString.
 	if strings.HasPrefix(e.Tag(), reasonString) {
 		return strings.TrimPrefix(e.Tag(), reasonString)
 	}
 	return fmt.Sprintf("%sfailed to validate Field: %s because of Tag: %s ",
-		reasonString,
+		reasonPrefix,
 		e.Field(),
 		e.Tag(),
 	)
 }
 
-func registerFieldValidator(key string, fn validator.Func) {
-	// We need to register the field validation funcs for all validators otherwise
-	// the validator panics on an unknown validation type.
-	validate.RegisterValidation(key, fn)
-}
-
-func registerStructValidator(validator *validator.Validate, fn validator.StructLevelFunc, t ...interface{}) {
-	validator.RegisterStructValidation(fn, t...)
-}
-
 func validateAction(fl validator.FieldLevel) bool {
 	s := fl.Field().String()
 	log.Debugf("Validate action: %s", s)
@@ -515,6 +765,60 @@ func validateIptablesBackend(fl validator.FieldLevel) bool {
 	return s == "" || s == api.IptablesBackendAuto || s == api.IptablesBackendNFTables || s == api.IptablesBackendLegacy
 }
 
+func validateNftSetElementType(fl validator.FieldLevel) bool {
+	s := fl.Field().String()
+	log.Debugf("Validate nft set element type: %s", s)
+	return s == "" || nftSetElementTypes.Contains(s)
+}
+
+// NftablesConfigurationSpec is the nftables-mode-only counterpart of FelixConfigurationSpec's
+// IptablesBackend: fields that only make sense once Felix is actually programming nft, such as
+// identifiers that name nft's own objects (chains, tables, sets) and nft's own hook priority
+// range. It's validated on its own, rather than as iptables-tagged fields on
+// FelixConfigurationSpec, because nft has a genuinely distinct schema from iptables -- set element
+// types, named priorities and identifier rules that don't have an iptables equivalent to piggyback
+// on. Felix would construct one from the nftables-relevant subset of its resolved config whenever
+// IptablesBackend is NFTables, so the legacy-only knobs below would only ever be populated (and so
+// only ever rejected) in that mode -- today nothing in felix or libcalico-go actually constructs or
+// validates one, so this struct is validator-only scaffolding ahead of that wiring.
+type NftablesConfigurationSpec struct {
+	// ChainNames are the nft chain names Felix will create.
+	ChainNames []string `json:"chainNames,omitempty" validate:"omitempty,dive,nftIdentifier"`
+	// TableNames are the nft table names Felix will create.
+	TableNames []string `json:"tableNames,omitempty" validate:"omitempty,dive,nftIdentifier"`
+	// SetNames are the nft named set names Felix will create.
+	SetNames []string `json:"setNames,omitempty" validate:"omitempty,dive,nftIdentifier"`
+
+	// Priority is this ruleset's priority within its netfilter hook, per nft's own priority
+	// model (-500..500, with 0 being the conventional "filter" priority).
+	Priority *int `json:"priority,omitempty"`
+
+	// SetElementType constrains the element type of any named sets Felix creates, to nft's own
+	// supported scalar types (ipv4_addr, ipv6_addr, ether_addr, inet_proto, inet_service, mark).
+	SetElementType string `json:"setElementType,omitempty" validate:"omitempty,nftSetElementType"`
+
+	// IptablesMangleAllowAction and IptablesFilterAllowAction are legacy iptables-only Felix
+	// knobs with no nftables equivalent. Felix never populates them on the NftablesConfigurationSpec
+	// it builds for nftables mode, so a non-nil value here always indicates the two backends'
+	// config have been mixed up.
+	IptablesMangleAllowAction *string `json:"iptablesMangleAllowAction,omitempty" validate:"omitempty,mustBeNil"`
+	IptablesFilterAllowAction *string `json:"iptablesFilterAllowAction,omitempty" validate:"omitempty,mustBeNil"`
+}
+
+const (
+	nftMinPriority = -500
+	nftMaxPriority = 500
+)
+
+func validateNftablesConfig(structLevel validator.StructLevel) {
+	n := structLevel.Current().Interface().(NftablesConfigurationSpec)
+
+	if n.Priority != nil && (*n.Priority < nftMinPriority || *n.Priority > nftMaxPriority) {
+		structLevel.ReportError(reflect.ValueOf(*n.Priority), "Priority", "",
+			reason(fmt.Sprintf("must be between %d and %d", nftMinPriority, nftMaxPriority)), "")
+	}
+}
+
 func validateLogLevel(fl validator.FieldLevel) bool {
 	s := fl.Field().String()
 	log.Debugf("Validate Felix log level: %s", s)
@@ -638,6 +942,20 @@ func validateProtocol(structLevel validator.StructLevel) {
 	}
 }
 
+// parsePrefix parses addr as either a bare IP address (assumed to be fully masked, i.e.
+// /32 or /128) or a CIDR. It is the single entry point the field validators below use
+// instead of each re-parsing strings via cnet.ParseCIDROrIP.
+func parsePrefix(addr string) (netip.Prefix, error) {
+	if p, err := netip.ParsePrefix(addr); err == nil {
+		return p, nil
+	}
+	ip, err := netip.ParseAddr(addr)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR or IP address %q", addr)
+	}
+	return netip.PrefixFrom(ip, ip.BitLen()), nil
+}
+
 // validateIPv4Network validates the field is a valid (strictly masked) IPv4 network.
 // An IP address is valid, and assumed to be fully masked (i.e /32)
 func validateIPv4Network(fl validator.FieldLevel) bool {
@@ -651,13 +969,12 @@ func validateIPv4Network(fl validator.FieldLevel) bool {
 }
 
 func ValidateIPv4Network(addr string) error {
-	ipa, ipn, err := cnet.ParseCIDROrIP(addr)
+	p, err := parsePrefix(addr)
 	if err != nil {
 		return err
 	}
-	// Check for the correct version and that the CIDR is correctly masked (by comparing the
-	// parsed IP against the IP in the parsed network).
-	if ipa.Version() == 4 && ipn.IP.String() == ipa.String() {
+	// Check for the correct version and that the CIDR is strictly masked.
+	if p.Addr().Is4() && p.Masked() == p {
 		return nil
 	}
 	return fmt.Errorf("Invalid IPv4 network %s", addr)
@@ -676,13 +993,12 @@ func validateIPv6Network(fl validator.FieldLevel) bool {
 }
 
 func ValidateIPv6Network(addr string) error {
-	ipa, ipn, err := cnet.ParseCIDROrIP(addr)
+	p, err := parsePrefix(addr)
 	if err != nil {
 		return err
 	}
-	// Check for the correct version and that the CIDR is correctly masked (by comparing the
-	// parsed IP against the IP in the parsed network).
-	if ipa.Version() == 6 && ipn.IP.String() == ipa.String() {
+	// Check for the correct version and that the CIDR is strictly masked.
+	if p.Addr().Is6() && !p.Addr().Is4In6() && p.Masked() == p {
 		return nil
 	}
 	return fmt.Errorf("Invalid IPv6 network %s", addr)
@@ -693,14 +1009,13 @@ func ValidateIPv6Network(addr string) error {
 func validateIPNetwork(fl validator.FieldLevel) bool {
 	n := fl.Field().String()
 	log.Debugf("Validate IP network: %s", n)
-	ipa, ipn, err := cnet.ParseCIDROrIP(n)
+	p, err := parsePrefix(n)
 	if err != nil {
 		return false
 	}
 
-	// Check  that the CIDR is correctly masked (by comparing the parsed IP against
-	// the IP in the parsed network).
-	return ipn.IP.String() == ipa.String()
+	// Check that the CIDR is strictly masked.
+	return p.Masked() == p
 }
 
 // validateCIDRv4 validates the field is a valid (not strictly masked) IPv4 network.
@@ -715,11 +1030,11 @@ func validateCIDRv4(fl validator.FieldLevel) bool {
 }
 
 func ValidateCIDRv4(cidr string) error {
-	ipa, _, err := cnet.ParseCIDROrIP(cidr)
+	p, err := parsePrefix(cidr)
 	if err != nil {
 		return err
 	}
-	if ipa.Version() == 4 {
+	if p.Addr().Is4() {
 		return nil
 	}
 	return fmt.Errorf("Invalid IPv4 CIDR: %s", cidr)
@@ -737,11 +1052,11 @@ func validateCIDRv6(fl validator.FieldLevel) bool {
 }
 
 func ValidateCIDRv6(cidr string) error {
-	ipa, _, err := cnet.ParseCIDROrIP(cidr)
+	p, err := parsePrefix(cidr)
 	if err != nil {
 		return err
 	}
-	if ipa.Version() == 6 {
+	if p.Addr().Is6() && !p.Addr().Is4In6() {
 		return nil
 	}
 	return fmt.Errorf("Invalid IPv6 CIDR: %s", cidr)
@@ -752,7 +1067,7 @@ func ValidateCIDRv6(cidr string) error {
 func validateCIDR(fl validator.FieldLevel) bool {
 	n := fl.Field().String()
 	log.Debugf("Validate IP network: %s", n)
-	_, _, err := cnet.ParseCIDROrIP(n)
+	_, err := parsePrefix(n)
 	return err == nil
 }
 
@@ -762,8 +1077,7 @@ func validateCIDRs(fl validator.FieldLevel) bool {
 	addrs := fl.Field().Interface().([]string)
 	log.Debugf("Validate IP CIDRs: %s", addrs)
 	for _, addr := range addrs {
-		_, _, err := cnet.ParseCIDROrIP(addr)
-		if err != nil {
+		if _, err := parsePrefix(addr); err != nil {
 			return false
 		}
 	}
@@ -773,17 +1087,17 @@ func validateCIDRs(fl validator.FieldLevel) bool {
 func validateIPv4(fl validator.FieldLevel) bool {
 	n := fl.Field().String()
 	log.Debugf("Validate IPv4: %s", n)
-	parsedIP := net.ParseIP(n)
+	addr, err := netip.ParseAddr(n)
 	// Check if parsing was successful and if it is an IPv4 address.
-	return parsedIP != nil && parsedIP.To4() != nil
+	return err == nil && addr.Is4()
 }
 
 func validateIPv6(fl validator.FieldLevel) bool {
 	n := fl.Field().String()
 	log.Debugf("Validate IPv6: %s", n)
-	parsedIP := net.ParseIP(n)
+	addr, err := netip.ParseAddr(n)
 	// Check if parsing was successful and if it is NOT an IPv4 address.
-	return parsedIP != nil && parsedIP.To4() == nil
+	return err == nil && !addr.Is4()
 }
 
 // validateKeyValueList validates the field is a comma separated list of key=value pairs.
@@ -818,41 +1132,34 @@ func validateIPPort(fl validator.FieldLevel) bool {
 	return ok
 }
 
+// parseAddrPort parses s as <IPv4>:<port> or [<IPv6>]:<port>.
+func parseAddrPort(s string) (netip.AddrPort, error) {
+	return netip.ParseAddrPort(s)
+}
+
 // processIPPort processes the IP and Port given in either <IPv4>:<port> or [<IPv6>]:<port> or <IP> format
 // and return the IP, port and a bool if the format is as expected
 func processIPPort(ipPort string) (string, int, bool) {
-	if ipPort != "" {
-		var ipStr, portStr string
-		var err error
-		var port uint64
-		ipStr = ipPort
-		// If PeerIP has both IP and port, validate both
-		if IPv4PortFormat.MatchString(ipPort) || IPv6PortFormat.MatchString(ipPort) {
-			ipStr, portStr, err = net.SplitHostPort(ipPort)
-			if err != nil {
-				log.Debugf("PeerIP value is invalid, it should either be \"<IP>\" or \"<IPv4>:<port>\" or \"[<IPv6>]:<port>\".")
-				return "", 0, false
-			}
-			port, err = strconv.ParseUint(portStr, 10, 16)
-			if err != nil {
-				log.Debugf("PeerIP value has invalid port.")
-				return "", 0, false
-			}
-			if port < 1 {
-				log.Debugf("PeerIP value has invalid port.")
-				return "", 0, false
-			}
-		}
+	if ipPort == "" {
+		return "", 0, false
+	}
 
-		parsedIP := net.ParseIP(ipStr)
-		if parsedIP == nil {
-			log.Debugf("PeerIP value is invalid.")
+	// If PeerIP has both IP and port, validate both.
+	if ap, err := parseAddrPort(ipPort); err == nil {
+		if ap.Port() < 1 {
+			log.Debugf("PeerIP value has invalid port.")
 			return "", 0, false
 		}
+		return ap.Addr().String(), int(ap.Port()), true
+	}
 
-		return ipStr, int(port), true
+	addr, err := netip.ParseAddr(ipPort)
+	if err != nil {
+		log.Debugf("PeerIP value is invalid, it should either be \"<IP>\" or \"<IPv4>:<port>\" or \"[<IPv6>]:<port>\".")
+		return "", 0, false
 	}
-	return "", 0, false
+
+	return addr.String(), 0, true
 }
 
 // validateHTTPMethods checks if the HTTP method match clauses are valid.
@@ -968,20 +1275,8 @@ func validateFelixConfigSpec(structLevel validator.StructLevel) {
 		}
 	}
 
-	// Validate that the externalNodesCIDRList is composed of valid cidr's.
-	if c.ExternalNodesCIDRList != nil {
-		for _, cidr := range *c.ExternalNodesCIDRList {
-			log.Debugf("Cidr is: %s", cidr)
-			ip, _, err := cnet.ParseCIDROrIP(cidr)
-			if err != nil {
-				structLevel.ReportError(reflect.ValueOf(cidr),
-					"ExternalNodesCIDRList", "", reason("has invalid CIDR(s)"), "")
-			} else if ip.Version() != 4 {
-				structLevel.ReportError(reflect.ValueOf(cidr),
-					"ExternalNodesCIDRList", "", reason("has invalid IPv6 CIDR"), "")
-			}
-		}
-	}
+	// Validate that the externalNodesCIDRList is composed of valid IPv4 cidr's.
+	validateExternalNodesCIDRList(structLevel, c.ExternalNodesCIDRList, "ExternalNodesCIDRList", 4)
 
 	// Validate that the OpenStack region is suitable for use in a namespace name.
 	const regionNamespacePrefix = "openstack-region-"
@@ -1013,14 +1308,6 @@ func validateFelixConfigSpec(structLevel validator.StructLevel) {
 		}
 	}
 
-	if c.DeviceRouteSourceAddressIPv6 != "" {
-		parsedAddress := cnet.ParseIP(c.DeviceRouteSourceAddressIPv6)
-		if parsedAddress == nil || parsedAddress.Version() != 6 {
-			structLevel.ReportError(reflect.ValueOf(c.DeviceRouteSourceAddressIPv6),
-				"DeviceRouteSourceAddressIPv6", "", reason("is not a valid IPv6 address"), "")
-		}
-	}
-
 	if c.RouteTableRange != nil && c.RouteTableRanges != nil {
 		structLevel.ReportError(reflect.ValueOf(c.RouteTableRange),
 			"RouteTableRange", "", reason("cannot be set when `RouteTableRanges` is also set"), "")
@@ -1032,6 +1319,29 @@ func validateFelixConfigSpec(structLevel validator.StructLevel) {
 	}
 }
 
+// validateExternalNodesCIDRList validates every entry of an ExternalNodesCIDRList-shaped field
+// is a valid CIDR of the given IP version.
+func validateExternalNodesCIDRList(structLevel validator.StructLevel, cidrs *[]string, fieldName string, version int) {
+	if cidrs == nil {
+		return
+	}
+	for _, cidr := range *cidrs {
+		log.Debugf("Cidr is: %s", cidr)
+		ip, _, err := cnet.ParseCIDROrIP(cidr)
+		if err != nil {
+			structLevel.ReportError(reflect.ValueOf(cidr),
+				fieldName, "", reason("has invalid CIDR(s)"), "")
+		} else if ip.Version() != version {
+			otherVersion := 4
+			if version == 4 {
+				otherVersion = 6
+			}
+			structLevel.ReportError(reflect.ValueOf(cidr),
+				fieldName, "", reason(fmt.Sprintf("has invalid IPv%d CIDR", otherVersion)), "")
+		}
+	}
+}
+
 func validateWorkloadEndpointSpec(structLevel validator.StructLevel) {
 	w := structLevel.Current().Interface().(libapi.WorkloadEndpointSpec)
 
@@ -1124,6 +1434,179 @@ func validateHostEndpointSpec(structLevel validator.StructLevel) {
 	}
 }
 
+// ReservedPrefix names a reserved or special-use IP range that an IPPoolSpec.CIDR is rejected for
+// overlapping, along with the operator-facing reason reported when it does.
+type ReservedPrefix struct {
+	Prefix netip.Prefix
+	Reason string
+}
+
+// reservedPrefixes is the table of reserved/special-use ranges validateIPPoolSpec checks every
+// IPPoolSpec.CIDR against, in addition to the IPAM backend's own reservations (see
+// calicoIPAMValidator.ReservedRanges for Calico IPAM's link-local ranges). Drawn from the
+// well-known IANA special-purpose registries for IPv4 and IPv6.
+var reservedPrefixes = []ReservedPrefix{
+	{netip.MustParsePrefix("127.0.0.0/8"), "IP pool range overlaps with IPv4 loopback range 127.0.0.0/8"},
+	{netip.MustParsePrefix("::1/128"), "IP pool range overlaps with IPv6 loopback address ::1/128"},
+	{netip.MustParsePrefix("224.0.0.0/4"), "IP pool range overlaps with IPv4 multicast range 224.0.0.0/4"},
+	{netip.MustParsePrefix("ff00::/8"), "IP pool range overlaps with IPv6 multicast range ff00::/8"},
+	{netip.MustParsePrefix("192.0.2.0/24"), "IP pool range overlaps with IPv4 documentation range 192.0.2.0/24 (TEST-NET-1)"},
+	{netip.MustParsePrefix("198.51.100.0/24"), "IP pool range overlaps with IPv4 documentation range 198.51.100.0/24 (TEST-NET-2)"},
+	{netip.MustParsePrefix("203.0.113.0/24"), "IP pool range overlaps with IPv4 documentation range 203.0.113.0/24 (TEST-NET-3)"},
+	{netip.MustParsePrefix("240.0.0.0/4"), "IP pool range overlaps with reserved IPv4 range 240.0.0.0/4"},
+	{netip.MustParsePrefix("2001:db8::/32"), "IP pool range overlaps with IPv6 documentation range 2001:db8::/32"},
+	{netip.MustParsePrefix("::ffff:0:0/96"), "IP pool range overlaps with IPv4-mapped IPv6 range ::ffff:0:0/96"},
+	{netip.MustParsePrefix("198.18.0.0/15"), "IP pool range overlaps with IPv4 benchmarking range 198.18.0.0/15"},
+}
+
+// ReservedPrefixes returns the table of reserved/special-use prefixes validateIPPoolSpec checks
+// an IPPoolSpec.CIDR against, for reuse by tools such as the calicoctl linter. It does not include
+// the IPAM backend's own reservations (e.g. Calico IPAM's link-local ranges), which are specific
+// to the backend rather than universal -- see the registered IPAMValidator's ReservedRanges.
+func ReservedPrefixes() []ReservedPrefix {
+	return append([]ReservedPrefix(nil), reservedPrefixes...)
+}
+
+// allowedReservedIPPoolCIDRs mirrors FelixConfiguration.AllowReservedIPPoolCIDRs: the literal
+// CIDR strings (matching a ReservedPrefixes() or IPAMValidator.ReservedRanges() entry) an operator
+// has opted in to allowing an IPPoolSpec.CIDR to overlap. Struct-level validators only ever see
+// the one object being validated, so this is set by the caller (the apiserver, from the cluster's
+// FelixConfiguration) ahead of validating IPPools, rather than threaded through Validate's single
+// argument.
+var allowedReservedIPPoolCIDRs = set.New[string]()
+
+// SetAllowedReservedIPPoolCIDRs configures the reserved prefixes -- from FelixConfiguration.
+// AllowReservedIPPoolCIDRs -- that validateIPPoolSpec should allow an IPPoolSpec.CIDR to overlap.
+func SetAllowedReservedIPPoolCIDRs(cidrs []string) {
+	allowedReservedIPPoolCIDRs = set.FromArray(cidrs)
+}
+
+// IPAMValidator is implemented by an IPAM backend that wants to supply its own struct-level
+// constraints for an api.IPPoolSpec, in place of the Calico IPAM rules validateIPPoolSpec used to
+// hardcode. A backend registers an implementation with RegisterIPAMValidator under the name it
+// expects an IPPool to select it by.
+type IPAMValidator interface {
+	// DefaultBlockSize returns the block size this backend assigns an IPPoolSpec whose
+	// BlockSize field is left unset, for the given IP version (4 or 6).
+	DefaultBlockSize(version int) int
+
+	// ValidatePool checks pool against this backend's own constraints -- e.g. minimum pool
+	// size relative to block size, or ranges it reserves for its own use -- and returns any
+	// violations. Syntactic checks (CIDR parses, is strictly masked) are performed by
+	// validateIPPoolSpec before ValidatePool is called.
+	ValidatePool(pool api.IPPoolSpec) field.ErrorList
+
+	// ReservedRanges returns the prefixes this backend never allows an IPPoolSpec.CIDR to
+	// overlap.
+	ReservedRanges() []netip.Prefix
+}
+
+// defaultIPAMBackend is the IPAM backend name validateIPPoolSpec dispatches on to select an
+// IPAMValidator. api.IPPoolSpec does not yet carry a field identifying its IPAM backend, so for
+// now every pool resolves to defaultIPAMBackend; the registry exists so that a caller wiring up
+// host-local or third-party IPAM can already register alongside "calico" and swap the dispatch
+// over once that field lands.
+const defaultIPAMBackend = "calico"
+
+var ipamValidators = map[string]IPAMValidator{}
+
+// RegisterIPAMValidator registers v as the IPAMValidator used for IPPoolSpecs whose IPAM backend
+// is name. Calling it again with the same name replaces the previous registration, which lets a
+// caller override the built-in "calico" entry as well as add new ones.
+func RegisterIPAMValidator(name string, v IPAMValidator) {
+	ipamValidators[name] = v
+}
+
+func init() {
+	RegisterIPAMValidator(defaultIPAMBackend, calicoIPAMValidator{})
+}
+
+// calicoIPAMValidator is the IPAMValidator for Calico's own IPAM: it enforces a minimum pool size
+// relative to the block size and reserves the IPv4 and IPv6 link-local ranges for itself.
+type calicoIPAMValidator struct{}
+
+func (calicoIPAMValidator) DefaultBlockSize(version int) int {
+	if version == 6 {
+		return 122
+	}
+	return 26
+}
+
+func (calicoIPAMValidator) ReservedRanges() []netip.Prefix {
+	return []netip.Prefix{
+		netip.MustParsePrefix("169.254.0.0/16"),
+		netip.MustParsePrefix("fe80::/10"),
+	}
+}
+
+func (v calicoIPAMValidator) ValidatePool(pool api.IPPoolSpec) field.ErrorList {
+	var errs field.ErrorList
+
+	p, err := parsePrefix(pool.CIDR)
+	if err != nil {
+		// Syntax is already reported by validateIPPoolSpec.
+		return errs
+	}
+
+	version := 4
+	if p.Addr().Is6() {
+		version = 6
+	}
+	blockSize := pool.BlockSize
+	if blockSize == 0 {
+		blockSize = v.DefaultBlockSize(version)
+	}
+
+	// The Calico IPAM places restrictions on the minimum IP pool size.  If
+	// the ippool is enabled, check that the pool is at least the minimum size.
+	if !pool.Disabled && p.Bits() > blockSize {
+		errs = append(errs, field.Invalid(field.NewPath("IPpool", "CIDR"), pool.CIDR,
+			"IP pool size is too small for use with Calico IPAM. It must be equal to or greater than the block size."))
+	}
+
+	for _, reserved := range v.ReservedRanges() {
+		if reserved.Addr().Is6() != p.Addr().Is6() {
+			continue
+		}
+		if reserved.Overlaps(p) && !allowedReservedIPPoolCIDRs.Contains(reserved.String()) {
+			msg := overlapsV4LinkLocal
+			if version == 6 {
+				msg = overlapsV6LinkLocal
+			}
+			errs = append(errs, field.Invalid(field.NewPath("IPpool", "CIDR"), pool.CIDR, msg))
+		}
+	}
+
+	// Beyond the IPAM backend's own reservations (e.g. link-local), reject overlap with the
+	// broader table of reserved/special-use ranges, unless an operator has opted a given range
+	// in via FelixConfiguration.AllowReservedIPPoolCIDRs.
+	for _, reserved := range reservedPrefixes {
+		if reserved.Prefix.Addr().Is6() != p.Addr().Is6() {
+			continue
+		}
+		if reserved.Prefix.Overlaps(p) && !allowedReservedIPPoolCIDRs.Contains(reserved.Prefix.String()) {
+			errs = append(errs, field.Invalid(field.NewPath("IPpool", "CIDR"), pool.CIDR, reserved.Reason))
+		}
+	}
+
+	isLoadBalancer := false
+	for _, u := range pool.AllowedUses {
+		if u == api.IPPoolAllowedUseLoadBalancer {
+			isLoadBalancer = true
+		}
+	}
+	if isLoadBalancer && pool.DisableBGPExport {
+		errs = append(errs, field.Invalid(field.NewPath("IPpool", "DisableBGPExport"), pool.DisableBGPExport,
+			"IP Pool with AllowedUse LoadBalancer must have DisableBGPExport set to true"))
+	}
+	if isLoadBalancer && pool.NodeSelector != "all()" {
+		errs = append(errs, field.Invalid(field.NewPath("IPpool", "NodeSelector"), pool.NodeSelector,
+			"IP Pool with AllowedUse LoadBalancer must have node selector set to all()"))
+	}
+
+	return errs
+}
+
 func validateIPPoolSpec(structLevel validator.StructLevel) {
 	pool := structLevel.Current().Interface().(api.IPPoolSpec)
 
@@ -1169,26 +1652,6 @@ func validateIPPoolSpec(structLevel validator.StructLevel) {
 			"IPpool.IPIPMode", "", reason("Neither IPIPMode nor VXLANMode can be enabled on AllowedUses LoadBalancer IP pool"), "")
 	}
 
-	// Default the blockSize
-	if pool.BlockSize == 0 {
-		if ipAddr.Version() == 4 {
-			pool.BlockSize = 26
-		} else {
-			pool.BlockSize = 122
-		}
-	}
-
-	// The Calico IPAM places restrictions on the minimum IP pool size.  If
-	// the ippool is enabled, check that the pool is at least the minimum size.
-	if !pool.Disabled {
-		ones, _ := cidr.Mask.Size()
-		log.Debugf("Pool CIDR: %s, mask: %d, blockSize: %d", cidr.String(), ones, pool.BlockSize)
-		if ones > pool.BlockSize {
-			structLevel.ReportError(reflect.ValueOf(pool.CIDR),
-				"IPpool.CIDR", "", reason("IP pool size is too small for use with Calico IPAM. It must be equal to or greater than the block size."), "")
-		}
-	}
-
 	// The Calico CIDR should be strictly masked
 	log.Debugf("IPPool CIDR: %s, Masked IP: %d", pool.CIDR, cidr.IP)
 	if cidr.IP.String() != ipAddr.String() {
@@ -1196,28 +1659,6 @@ func validateIPPoolSpec(structLevel validator.StructLevel) {
 			"IPpool.CIDR", "", reason(poolUnstictCIDR), "")
 	}
 
-	// IPv4 link local subnet.
-	ipv4LinkLocalNet := net.IPNet{
-		IP:   net.ParseIP("169.254.0.0"),
-		Mask: net.CIDRMask(16, 32),
-	}
-	// IPv6 link local subnet.
-	ipv6LinkLocalNet := net.IPNet{
-		IP:   net.ParseIP("fe80::"),
-		Mask: net.CIDRMask(10, 128),
-	}
-
-	// IP Pool CIDR cannot overlap with IPv4 or IPv6 link local address range.
-	if cidr.Version() == 4 && cidr.IsNetOverlap(ipv4LinkLocalNet) {
-		structLevel.ReportError(reflect.ValueOf(pool.CIDR),
-			"IPpool.CIDR", "", reason(overlapsV4LinkLocal), "")
-	}
-
-	if cidr.Version() == 6 && cidr.IsNetOverlap(ipv6LinkLocalNet) {
-		structLevel.ReportError(reflect.ValueOf(pool.CIDR),
-			"IPpool.CIDR", "", reason(overlapsV6LinkLocal), "")
-	}
-
 	// Allowed use must be one of the enums.
 	for _, a := range pool.AllowedUses {
 		switch a {
@@ -1235,14 +1676,14 @@ func validateIPPoolSpec(structLevel validator.StructLevel) {
 		}
 	}
 
-	if isLoadBalancer && pool.DisableBGPExport {
-		structLevel.ReportError(reflect.ValueOf(pool.CIDR),
-			"IPpool.DisableBGPExport", "", reason("IP Pool with AllowedUse LoadBalancer must have DisableBGPExport set to true"), "")
+	// Dispatch the IPAM-specific constraints (minimum pool size, reserved ranges, LoadBalancer
+	// rules) to the registered IPAMValidator for this pool's backend.
+	ipamValidator, ok := ipamValidators[defaultIPAMBackend]
+	if !ok {
+		return
 	}
-
-	if isLoadBalancer && pool.NodeSelector != "all()" {
-		structLevel.ReportError(reflect.ValueOf(pool.CIDR),
-			"IPpool.NodeSelector", "", reason("IP Pool with AllowedUse LoadBalancer must have node selector set to all()"), "")
+	for _, e := range ipamValidator.ValidatePool(pool) {
+		structLevel.ReportError(reflect.ValueOf(e.BadValue), e.Field, "", reason(e.Detail), "")
 	}
 }
 
@@ -1763,6 +2204,7 @@ func validateNetworkPolicySpec(spec *api.NetworkPolicySpec, structLevel validato
 			reason(globalSelectorEntRule),
 			"")
 	}
+
 }
 
 func validateNetworkPolicy(structLevel validator.StructLevel) {
@@ -1984,6 +2426,7 @@ func validateGlobalNetworkPolicySpec(spec *api.GlobalNetworkPolicySpec, structLe
 			reason(globalSelectorEntRule),
 			"")
 	}
+
 }
 
 func validateGlobalNetworkPolicy(structLevel validator.StructLevel) {
@@ -2302,11 +2745,15 @@ func isCommunityDefined(community string, communityKVPairs []api.Community) bool
 }
 
 func isValidCommunity(communityValue string, fieldName string, structLevel validator.StructLevel) bool {
-	if standardCommunity.MatchString(communityValue) {
+	switch {
+	case standardCommunity.MatchString(communityValue):
 		validateCommunityValue(communityValue, fieldName, structLevel, false)
-	} else if largeCommunity.MatchString(communityValue) {
+	case largeCommunity.MatchString(communityValue):
 		validateCommunityValue(communityValue, fieldName, structLevel, true)
-	} else {
+	case extCommunityTwoOctetAS.MatchString(communityValue) || extCommunityFourOctetAS.MatchString(communityValue) ||
+		extCommunityIPv4.MatchString(communityValue) || extCommunityOpaque.MatchString(communityValue):
+		validateExtendedCommunityValue(communityValue, fieldName, structLevel)
+	default:
 		return false
 	}
 	return true
@@ -2331,6 +2778,44 @@ func validateCommunityValue(val string, fieldName string, structLevel validator.
 	}
 }
 
+// validateExtendedCommunityValue checks an RFC 4360 extended community's numeric fields fit the
+// ranges its sub-type defines: a 16-bit AS and 32-bit local admin for the two-octet AS form, a
+// (dotted) 32-bit AS and 16-bit local admin for the four-octet AS form, a dotted-quad IPv4 address
+// and 16-bit local admin for the IPv4-address-specific form, and up to 48 bits of hex for opaque.
+func validateExtendedCommunityValue(val string, fieldName string, structLevel validator.StructLevel) {
+	checkBits := func(s string, bitSize int) {
+		if _, err := strconv.ParseUint(s, 10, bitSize); err != nil {
+			structLevel.ReportError(reflect.ValueOf(val), fieldName, "",
+				reason(fmt.Sprintf("invalid extended community value, expected %d bit value", bitSize)), "")
+		}
+	}
+
+	switch {
+	case extCommunityTwoOctetAS.MatchString(val):
+		m := extCommunityTwoOctetAS.FindStringSubmatch(val)
+		checkBits(m[2], 16)
+		checkBits(m[3], 32)
+	case extCommunityFourOctetAS.MatchString(val):
+		m := extCommunityFourOctetAS.FindStringSubmatch(val)
+		checkBits(m[2], 16)
+		checkBits(m[3], 16)
+		checkBits(m[4], 16)
+	case extCommunityIPv4.MatchString(val):
+		m := extCommunityIPv4.FindStringSubmatch(val)
+		if cnet.ParseIP(m[2]) == nil {
+			structLevel.ReportError(reflect.ValueOf(val), fieldName, "",
+				reason("invalid IPv4-address-specific extended community: not a valid IPv4 address"), "")
+		}
+		checkBits(m[3], 16)
+	case extCommunityOpaque.MatchString(val):
+		m := extCommunityOpaque.FindStringSubmatch(val)
+		if len(m[1]) > 12 {
+			structLevel.ReportError(reflect.ValueOf(val), fieldName, "",
+				reason("invalid opaque extended community: value must fit in 48 bits (12 hex digits)"), "")
+		}
+	}
+}
+
 // ruleUsesAppLayerPolicy checks if a rule uses application layer policy, and
 // if it does, returns true and the type of application layer clause. If it does
 // not it returns false and the empty string.
@@ -12,6 +12,8 @@ import (
 	"text/template"
 
 	"github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/calico/release/internal/command"
 )
 
 // A brief note on Ubuntu/Debian/apt repo terminology:
@@ -54,6 +56,21 @@ import (
 // Hopefully this explains why 'suite' and 'codename' are used mostly interchangeably in
 // this code depending on what they're actually being used for!
 
+// Format selects which sources-file format(s) writeAptSourcesFile emits for a suite.
+type Format int
+
+const (
+	// FormatDeb822 emits only the deb822-style <suite>.sources file, understood by apt >= 2.4
+	// (Ubuntu 22.04/noble+, Debian 12/bookworm+).
+	FormatDeb822 Format = iota
+	// FormatLegacy emits only the legacy one-line <suite>.list file (deb + deb-src stanzas) plus
+	// a dearmored keyring, for clients that predate deb822 support.
+	FormatLegacy
+	// FormatBoth emits both the deb822 .sources file and the legacy .list/keyring pair, for
+	// suites that need to support old and new apt clients side by side.
+	FormatBoth
+)
+
 type aptSourcesData struct {
 	// RepoName is the name of the repository as might be shown by repolib (e.g. in a UI)
 	RepoName string
@@ -61,19 +78,47 @@ type aptSourcesData struct {
 	RepoURL string
 	// Suite is the 'suite' field, e.g. noble, bookworm, etc.
 	Suite string
-	// GpgKey is the ascii-armored GPG public key
+	// Origin identifies the repo owner, used to derive the legacy keyring's file name
+	// (<origin>-archive-keyring.gpg); has no effect on the deb822 .sources file.
+	Origin string
+	// GpgKey is the ascii-armored GPG public key, formatted for inline embedding in the deb822
+	// Signed-By field.
 	GpgKey string
+	// RawGpgKey is the unmodified ascii-armored GPG public key, as returned by
+	// utils.GetGPGPubKey, used to produce the dearmored keyring the legacy .list file references.
+	RawGpgKey string
 	// Architectures is the list of architectures this sources file will claim support for
 	Architectures []string
+	// Format selects which of the deb822/legacy sources file(s) to write.
+	Format Format
 }
 
 //go:embed repo.sources.gotmpl
 var aptSourcesTemplate string
 
-// writeAptSourcesFile creates a deb822-style sources file for a given set
+//go:embed repo.list.gotmpl
+var aptSourcesListTemplate string
+
+// writeAptSourcesFile writes the sources file(s) for a given set of parameters under rootPath,
+// in the format(s) selected by asd.Format.
+func (asd *aptSourcesData) writeAptSourcesFile(rootPath string) error {
+	if asd.Format == FormatDeb822 || asd.Format == FormatBoth {
+		if err := asd.writeDeb822SourcesFile(rootPath); err != nil {
+			return err
+		}
+	}
+	if asd.Format == FormatLegacy || asd.Format == FormatBoth {
+		if err := asd.writeLegacySourcesFile(rootPath); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeDeb822SourcesFile creates a deb822-style sources file for a given set
 // of parameters, and writes it to <suite>.sources under <rootPath>
 // For more info on the format: https://repolib.readthedocs.io/en/latest/deb822-format.html
-func (asd *aptSourcesData) writeAptSourcesFile(rootPath string) error {
+func (asd *aptSourcesData) writeDeb822SourcesFile(rootPath string) error {
 	logrus.WithField("suite", asd.Suite).Info("Generating apt .sources file")
 	sourcesFilePath := filepath.Join(rootPath, fmt.Sprintf("%s.sources", asd.Suite))
 	sourcesFile, err := os.OpenFile(sourcesFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
@@ -101,14 +146,105 @@ func (asd *aptSourcesData) writeAptSourcesFile(rootPath string) error {
 	return nil
 }
 
-func getVersionFromDebfile(debfilePath string) (string, error) {
-	logrus.WithField("debfile", debfilePath).Debug("Getting version information from debian package")
-	cmd := exec.Command("dpkg-deb", "--show", "--showformat", "${Version}", debfilePath)
+// writeLegacySourcesFile creates the pre-deb822 <suite>.list one-line sources snippet (a deb and a
+// matching deb-src stanza) for clients too old to parse deb822 .sources files (apt < 2.4, i.e.
+// Ubuntu <22.04 and Debian <12), and drops the keyring the snippet's signed-by option references
+// next to it.
+func (asd *aptSourcesData) writeLegacySourcesFile(rootPath string) error {
+	logrus.WithField("suite", asd.Suite).Info("Generating legacy apt .list file")
+
+	keyringFile := keyringFileName(asd.Origin)
+	keyring, err := dearmorGPGKey(asd.RawGpgKey)
+	if err != nil {
+		return fmt.Errorf("dearmoring GPG key for %s: %w", keyringFile, err)
+	}
+	if err := os.WriteFile(filepath.Join(rootPath, keyringFile), keyring, 0o644); err != nil {
+		return fmt.Errorf("writing keyring %s: %w", keyringFile, err)
+	}
+
+	listFilePath := filepath.Join(rootPath, fmt.Sprintf("%s.list", asd.Suite))
+	listFile, err := os.OpenFile(listFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("opening %s: %w", listFilePath, err)
+	}
+	defer func() { _ = listFile.Close() }()
+
+	funcMap := template.FuncMap{
+		"join": strings.Join,
+	}
+
+	tmpl, err := template.New("apt.list").Funcs(funcMap).Parse(aptSourcesListTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse legacy apt sources template: %w", err)
+	}
+
+	if err := tmpl.Execute(listFile, struct {
+		aptSourcesData
+		KeyringFile string
+	}{*asd, keyringFile}); err != nil {
+		logrus.WithField("suite", asd.Suite).WithError(err).Error("failed to write legacy apt sources file")
+		return fmt.Errorf("failed to write legacy apt sources file: %w", err)
+	}
+
+	logrus.WithField("file", listFilePath).Info("Wrote legacy apt .list file")
+
+	return nil
+}
+
+// keyringFileName derives the <origin>-archive-keyring.gpg name used both for the dearmored
+// keyring file written alongside a .list file and for the signed-by path it references, by
+// lowercasing origin and replacing every non alphanumeric character with a hyphen.
+func keyringFileName(origin string) string {
+	sanitized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + ('a' - 'A')
+		default:
+			return '-'
+		}
+	}, origin)
+	return fmt.Sprintf("%s-archive-keyring.gpg", sanitized)
+}
+
+// DebPackage describes one .deb file discovered by ScanDebs: the source package it was built from
+// in addition to the suite/binary identity a plain per-suite grouping would report alone. The
+// source fields are what let pool placement and Sources index generation group sibling binaries
+// back to the source package they came from, instead of only knowing the suite they belong to.
+type DebPackage struct {
+	// Path is the .deb/.ddeb file's path on disk, as found under one of ScanDebs' searchPaths.
+	Path string
+	// BinaryName is the control stanza's Package field.
+	BinaryName string
+	// SourceName is the source package name: the control stanza's Source field if present
+	// (stripped of any "(version)" suffix), else BinaryName.
+	SourceName string
+	// SourceVersion is the version of SourceName that produced this binary: the version in
+	// Source's "(version)" suffix if present (e.g. after a binNMU), else BinaryVersion.
+	SourceVersion string
+	// BinaryVersion is the control stanza's Version field.
+	BinaryVersion string
+	// Architecture is the control stanza's Architecture field.
+	Architecture string
+	// Suite is the tilde-suffixed tail of BinaryVersion, e.g. "1.2.3~noble" -> "noble".
+	Suite string
+}
+
+// debFieldNames are the control fields getDebFields reads from a .deb via dpkg-deb --field.
+var debFieldNames = []string{"Package", "Source", "Version", "Architecture"}
+
+// getDebFields reads debFieldNames out of a .deb's control stanza via dpkg-deb --field, and parses
+// the "Key: Value" output with the same stanza parser readControlStanza uses.
+func getDebFields(debfilePath string) (map[string]string, error) {
+	logrus.WithField("debfile", debfilePath).Debug("Getting control fields from debian package")
+	args := append([]string{"--field", debfilePath}, debFieldNames...)
+	cmd := exec.Command("dpkg-deb", args...)
 	out, err := cmd.Output()
 	if err != nil {
-		return "", fmt.Errorf("getting version for %s: %w", debfilePath, err)
+		return nil, fmt.Errorf("getting control fields for %s: %w", debfilePath, err)
 	}
-	return string(out), nil
+	return parseControlStanza(string(out)), nil
 }
 
 func getComponentNameFromVersion(version string) (string, error) {
@@ -118,18 +254,83 @@ func getComponentNameFromVersion(version string) (string, error) {
 	return "", fmt.Errorf("version %s does not contain a tilde separator", version)
 }
 
-func getSuiteNameFromDebFile(debfilePath string) (string, error) {
-	version, err := getVersionFromDebfile(debfilePath)
+// parseSourceField splits a binary package's control-stanza Source field into the source
+// package's name and version: "foo (1.2-3)" when the source was built at a different version
+// than the binary (e.g. a binNMU), or plain "foo" when source and binary share a version, in
+// which case binaryVersion is also the source version.
+func parseSourceField(source, binaryVersion string) (name, version string) {
+	source = strings.TrimSpace(source)
+	if idx := strings.Index(source, "("); idx != -1 {
+		name = strings.TrimSpace(source[:idx])
+		version = strings.TrimSuffix(strings.TrimSpace(source[idx+1:]), ")")
+		return name, version
+	}
+	return source, binaryVersion
+}
+
+// scanDebPackage reads a .deb's control fields via getDebFields and populates a DebPackage,
+// deriving Suite the same way getComponentNameFromVersion always has: the tilde-suffixed tail of the
+// binary Version field.
+func scanDebPackage(debfilePath string) (DebPackage, error) {
+	fields, err := getDebFields(debfilePath)
 	if err != nil {
-		return "", fmt.Errorf("getting version for %s: %w", debfilePath, err)
+		return DebPackage{}, err
 	}
 
-	suite, err := getComponentNameFromVersion(version)
+	binaryVersion := fields["Version"]
+	suite, err := getComponentNameFromVersion(binaryVersion)
 	if err != nil {
-		return "", fmt.Errorf("getting component name for %s: %w", debfilePath, err)
+		return DebPackage{}, fmt.Errorf("getting suite for %s: %w", debfilePath, err)
+	}
+
+	sourceName, sourceVersion := parseSourceField(fields["Source"], binaryVersion)
+	if sourceName == "" {
+		sourceName = fields["Package"]
+	}
+
+	return DebPackage{
+		Path:          debfilePath,
+		BinaryName:    fields["Package"],
+		SourceName:    sourceName,
+		SourceVersion: sourceVersion,
+		BinaryVersion: binaryVersion,
+		Architecture:  fields["Architecture"],
+		Suite:         suite,
+	}, nil
+}
+
+// ScanDebs parses every .deb/.ddeb file under searchPaths into a DebPackage and groups the results
+// by Suite, the same bucketing RecursiveAddDebsFromDirectories has always used. Unlike a
+// suite-only grouping, each DebPackage also keeps the source package name/version, so callers can
+// further group siblings built from the same source (e.g. for pool/<component>/<letter>/<source>/
+// placement or a Sources index), and ScanDebs rejects any two .deb files that would collide on the
+// same Package_Version_Arch.deb pool filename.
+func ScanDebs(searchPaths []string) (map[string][]DebPackage, error) {
+	files, err := getRecursiveDebs(searchPaths)
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Debugf("Found %d debian package files to process", len(files))
+
+	bySuite := map[string][]DebPackage{}
+	seenFilenames := map[string]string{}
+	for _, debFile := range files {
+		pkg, err := scanDebPackage(debFile)
+		if err != nil {
+			return nil, fmt.Errorf("scanning %s: %w", debFile, err)
+		}
+
+		poolFilename := fmt.Sprintf("%s_%s_%s.deb", pkg.BinaryName, pkg.BinaryVersion, pkg.Architecture)
+		if existing, ok := seenFilenames[poolFilename]; ok {
+			return nil, fmt.Errorf("%s and %s both resolve to pool filename %s: duplicate Package/Version/Architecture", existing, debFile, poolFilename)
+		}
+		seenFilenames[poolFilename] = debFile
+
+		bySuite[pkg.Suite] = append(bySuite[pkg.Suite], pkg)
 	}
 
-	return suite, nil
+	return bySuite, nil
 }
 
 // formatGPGKeyForSourcesFile formats a GPG public key into a format suitable to
@@ -157,24 +358,31 @@ func formatGPGKeyForSourcesFile(gpgKey string) string {
 	return processedKey.String()
 }
 
-func getRecursiveDebsBySuite(searchPaths []string) (map[string][]string, error) {
-	debsBySuite := make(map[string][]string, 0)
-
-	files, err := getRecursiveDebs(searchPaths)
+// dearmorGPGKey converts an ascii-armored GPG public key into the binary keyring format apt's
+// signed-by option expects, by round-tripping it through `gpg --dearmor` via a temp file (gpg
+// only dearmors from a file or stdin, and command.Run doesn't give us a way to pipe stdin).
+func dearmorGPGKey(armoredKey string) ([]byte, error) {
+	tmpFile, err := os.CreateTemp("", "calico-apt-keyring-*.asc")
 	if err != nil {
-		return map[string][]string{}, err
+		return nil, fmt.Errorf("creating temp file: %w", err)
 	}
+	defer func() { _ = os.Remove(tmpFile.Name()) }()
 
-	logrus.Debugf("Found %d debian package files to process", len(files))
-	for _, debFile := range files {
-		suite, err := getSuiteNameFromDebFile(debFile)
-		if err != nil {
-			return map[string][]string{}, fmt.Errorf("getting suite name for %s: %w", debFile, err)
-		}
-		debsBySuite[suite] = append(debsBySuite[suite], debFile)
+	if _, err := tmpFile.WriteString(armoredKey); err != nil {
+		_ = tmpFile.Close()
+		return nil, fmt.Errorf("writing armored key to temp file: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return nil, fmt.Errorf("closing temp file: %w", err)
 	}
 
-	return debsBySuite, nil
+	cmdArgs := []string{"--dearmor", "--output", "-", tmpFile.Name()}
+	logrus.Debugf("running gpg with args %s", strings.Join(cmdArgs, " "))
+	out, err := command.Run("gpg", cmdArgs)
+	if err != nil {
+		return nil, fmt.Errorf("dearmoring gpg key: %w", err)
+	}
+	return []byte(out), nil
 }
 
 func getRecursiveDebs(searchPaths []string) ([]string, error) {
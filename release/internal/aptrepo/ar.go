@@ -0,0 +1,81 @@
+package aptrepo
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// arMagic is the fixed 8-byte header that begins every Unix ar archive, which is the outer
+// container format .deb files use (a control.tar.*, data.tar.*, and debian-binary member).
+const arMagic = "!<arch>\n"
+
+// arEntry is one member of an ar archive.
+type arEntry struct {
+	Name string
+	Size int64
+	Data []byte
+}
+
+// parseAr parses a Unix ar archive (the outer container of a .deb file) and returns its members
+// in order. It implements just enough of the format (fixed 60-byte headers, even-byte padding)
+// to extract the debian-binary/control.tar.*/data.tar.* members dpkg writes.
+func parseAr(r io.Reader) ([]arEntry, error) {
+	br := bufio.NewReader(r)
+
+	magic := make([]byte, len(arMagic))
+	if _, err := io.ReadFull(br, magic); err != nil {
+		return nil, fmt.Errorf("reading ar magic: %w", err)
+	}
+	if string(magic) != arMagic {
+		return nil, fmt.Errorf("not an ar archive (bad magic %q)", magic)
+	}
+
+	var entries []arEntry
+	header := make([]byte, 60)
+	for {
+		_, err := io.ReadFull(br, header)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading ar header: %w", err)
+		}
+
+		name := strings.TrimRight(string(header[0:16]), " ")
+		name = strings.TrimSuffix(name, "/") // GNU ar convention
+		sizeStr := strings.TrimSpace(string(header[48:58]))
+		size, err := strconv.ParseInt(sizeStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing ar entry size for %s: %w", name, err)
+		}
+
+		data := make([]byte, size)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return nil, fmt.Errorf("reading ar entry %s: %w", name, err)
+		}
+		entries = append(entries, arEntry{Name: name, Size: size, Data: data})
+
+		if size%2 == 1 {
+			// Members are padded to an even number of bytes.
+			if _, err := br.Discard(1); err != nil && err != io.EOF {
+				return nil, fmt.Errorf("discarding ar padding byte after %s: %w", name, err)
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+// findArMember returns the data for the first ar member whose name has the given prefix, e.g.
+// "control.tar" to find any of control.tar.gz/.xz/.zst.
+func findArMember(entries []arEntry, prefix string) (arEntry, bool) {
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name, prefix) {
+			return e, true
+		}
+	}
+	return arEntry{}, false
+}
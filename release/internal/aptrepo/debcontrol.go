@@ -0,0 +1,167 @@
+package aptrepo
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// ControlFields holds the parsed key/value stanza from a .deb's control file, plus the file-level
+// metadata (Filename/Size/checksums) that Packages indices require but which isn't part of the
+// control stanza itself.
+type ControlFields struct {
+	// Fields holds every field from the control stanza (Package, Version, Architecture,
+	// Source, Maintainer, Depends, Description, ...), keyed by field name.
+	Fields map[string]string
+
+	Filename string
+	Size     int64
+	MD5Sum   string
+	SHA1     string
+	SHA256   string
+}
+
+// Get returns a control field's value, or "" if it wasn't present.
+func (c ControlFields) Get(name string) string { return c.Fields[name] }
+
+// readControlStanza extracts and parses the "control" file out of a .deb's control.tar.{gz,xz,zst}
+// member.
+func readControlStanza(debPath string) (ControlFields, error) {
+	f, err := os.Open(debPath)
+	if err != nil {
+		return ControlFields{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	// Read the whole file into memory once; the file-level checksums and the ar parser below
+	// both work from this buffer, and the size comes from os.Stat.
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, f); err != nil {
+		return ControlFields{}, fmt.Errorf("reading %s: %w", debPath, err)
+	}
+
+	info, err := os.Stat(debPath)
+	if err != nil {
+		return ControlFields{}, err
+	}
+
+	md5h, sha1h, sha256h := md5.New(), sha1.New(), sha256.New()
+	for _, h := range []hash.Hash{md5h, sha1h, sha256h} {
+		if _, err := h.Write(buf.Bytes()); err != nil {
+			return ControlFields{}, err
+		}
+	}
+
+	entries, err := parseAr(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		return ControlFields{}, fmt.Errorf("parsing %s as ar archive: %w", debPath, err)
+	}
+
+	member, ok := findArMember(entries, "control.tar")
+	if !ok {
+		return ControlFields{}, fmt.Errorf("%s has no control.tar.* member", debPath)
+	}
+
+	controlBytes, err := extractControlFile(member)
+	if err != nil {
+		return ControlFields{}, fmt.Errorf("extracting control file from %s: %w", debPath, err)
+	}
+
+	fields := parseControlStanza(string(controlBytes))
+
+	return ControlFields{
+		Fields:   fields,
+		Filename: debPath,
+		Size:     info.Size(),
+		MD5Sum:   fmt.Sprintf("%x", md5h.Sum(nil)),
+		SHA1:     fmt.Sprintf("%x", sha1h.Sum(nil)),
+		SHA256:   fmt.Sprintf("%x", sha256h.Sum(nil)),
+	}, nil
+}
+
+// extractControlFile decompresses a control.tar.{gz,xz,zst} ar member and returns the bytes of
+// the "./control" (or "control") entry within it.
+func extractControlFile(member arEntry) ([]byte, error) {
+	var tarReader io.Reader
+	switch {
+	case strings.HasSuffix(member.Name, ".gz"):
+		gz, err := gzip.NewReader(bytes.NewReader(member.Data))
+		if err != nil {
+			return nil, fmt.Errorf("opening gzip control archive: %w", err)
+		}
+		defer func() { _ = gz.Close() }()
+		tarReader = gz
+	case strings.HasSuffix(member.Name, ".xz"):
+		xr, err := xz.NewReader(bytes.NewReader(member.Data))
+		if err != nil {
+			return nil, fmt.Errorf("opening xz control archive: %w", err)
+		}
+		tarReader = xr
+	case strings.HasSuffix(member.Name, ".zst"):
+		zr, err := zstd.NewReader(bytes.NewReader(member.Data))
+		if err != nil {
+			return nil, fmt.Errorf("opening zstd control archive: %w", err)
+		}
+		defer zr.Close()
+		tarReader = zr
+	default:
+		return nil, fmt.Errorf("unsupported control archive compression: %s", member.Name)
+	}
+
+	tr := tar.NewReader(tarReader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading control tar: %w", err)
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		if name == "control" {
+			data, err := io.ReadAll(tr)
+			if err != nil {
+				return nil, fmt.Errorf("reading control entry: %w", err)
+			}
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("control.tar has no control entry")
+}
+
+// parseControlStanza parses an RFC 2822-ish Debian control stanza (simple "Key: Value" lines,
+// with continuation lines indented by whitespace) into a flat map. Multi-line values are joined
+// with "\n" per Debian's own convention for e.g. the Description field.
+func parseControlStanza(raw string) map[string]string {
+	fields := map[string]string{}
+	var lastKey string
+	for _, line := range strings.Split(raw, "\n") {
+		if line == "" {
+			continue
+		}
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && lastKey != "" {
+			fields[lastKey] += "\n" + strings.TrimPrefix(line, " ")
+			continue
+		}
+		idx := strings.Index(line, ":")
+		if idx < 0 {
+			continue
+		}
+		key := strings.TrimSpace(line[:idx])
+		val := strings.TrimSpace(line[idx+1:])
+		fields[key] = val
+		lastKey = key
+	}
+	return fields
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2026 Tigera, Inc. All rights reserved.
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aptrepo
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// TestParseControlStanza checks field parsing and the Description-style continuation-line
+// joining, per Debian's control file convention.
+func TestParseControlStanza(t *testing.T) {
+	raw := "Package: calico-felix\n" +
+		"Version: 3.30.0\n" +
+		"Architecture: amd64\n" +
+		"Source: felix\n" +
+		"Description: Calico's per-host daemon\n" +
+		" Programs the dataplane on each node.\n" +
+		" Second continuation line.\n"
+
+	fields := parseControlStanza(raw)
+
+	for name, want := range map[string]string{
+		"Package":      "calico-felix",
+		"Version":      "3.30.0",
+		"Architecture": "amd64",
+		"Source":       "felix",
+	} {
+		if got := fields[name]; got != want {
+			t.Errorf("field %s = %q, want %q", name, got, want)
+		}
+	}
+
+	wantDescription := "Calico's per-host daemon\nPrograms the dataplane on each node.\nSecond continuation line."
+	if got := fields["Description"]; got != wantDescription {
+		t.Errorf("Description = %q, want %q", got, wantDescription)
+	}
+}
+
+// TestParseArRoundTrip builds a minimal ar archive by hand (the same layout dpkg writes a .deb
+// as) and checks that parseAr/findArMember recover each member's name and bytes, including the
+// odd-length-member padding byte.
+func TestParseArRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(arMagic)
+	writeArEntry(&buf, "debian-binary", []byte("2.0\n"))
+	writeArEntry(&buf, "control.tar.gz", []byte{0x1f, 0x8b, 0x03}) // odd length, exercises padding.
+	writeArEntry(&buf, "data.tar.xz", []byte{0xfd, 0x37, 0x7a, 0x58})
+
+	entries, err := parseAr(&buf)
+	if err != nil {
+		t.Fatalf("parseAr() returned an error: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 ar entries, got %d", len(entries))
+	}
+
+	member, ok := findArMember(entries, "control.tar")
+	if !ok {
+		t.Fatal("findArMember() did not find control.tar.gz")
+	}
+	if !bytes.Equal(member.Data, []byte{0x1f, 0x8b, 0x03}) {
+		t.Errorf("control.tar.gz data = %x, want 1f8b03", member.Data)
+	}
+
+	if _, ok := findArMember(entries, "nonexistent"); ok {
+		t.Error("findArMember() found a member that wasn't in the archive")
+	}
+}
+
+// writeArEntry appends one ar member (60-byte header plus data, padded to an even length) to buf,
+// mirroring the subset of the format parseAr understands.
+func writeArEntry(buf *bytes.Buffer, name string, data []byte) {
+	header := make([]byte, 60)
+	for i := range header {
+		header[i] = ' '
+	}
+	copy(header[0:16], name+"/")
+	copy(header[48:58], []byte(fmt.Sprintf("%-10d", len(data))))
+	buf.Write(header)
+	buf.Write(data)
+	if len(data)%2 == 1 {
+		buf.WriteByte('\n')
+	}
+}
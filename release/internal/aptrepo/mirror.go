@@ -0,0 +1,381 @@
+package aptrepo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ulikunitz/xz"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/calico/release/internal/utils"
+)
+
+// MirrorConfig describes an upstream apt repository to pull packages from into this Repo's pool,
+// so staged releases and airgapped rebuilds can seed the local repo from a previous Calico release
+// or a distro mirror before layering new debs on top via IncludeDeb, rather than depending on
+// debmirror or aptly mirror.
+type MirrorConfig struct {
+	// BaseURL is the upstream repo root, the same form as Repo.PublishingURL (dists/ and pool/
+	// are expected directly underneath it).
+	BaseURL string
+	// Suite is the upstream suite to mirror, e.g. noble, bookworm -- not necessarily one of this
+	// Repo's own Config.Components, though it usually will be.
+	Suite string
+	// Components is the list of components to mirror from the upstream suite. Each must also be
+	// present in this Repo's own Config.Components, since materializing a package goes through
+	// the ordinary IncludeDeb path.
+	Components []string
+	// Architectures restricts which binary-<arch> Packages indices are mirrored.
+	Architectures []string
+	// TrustedKeyring is the upstream's ascii-armored public key(s). InRelease's clearsign must
+	// verify against one of them, or MirrorUpstream refuses to trust anything it downloaded.
+	TrustedKeyring string
+	// CacheDir is where downloaded .deb files are cached, keyed by their SHA256, so mirroring an
+	// overlapping suite later doesn't re-download anything already on disk.
+	CacheDir string
+	// Concurrency bounds how many .deb downloads run at once. Defaults to 4 if <= 0.
+	Concurrency int
+	// DryRun, if true, resolves and logs every package that would be fetched without downloading
+	// or materializing anything.
+	DryRun bool
+}
+
+// upstreamPackage is one stanza of an upstream Packages index, trimmed to the fields
+// MirrorUpstream needs to download and verify the .deb it describes.
+type upstreamPackage struct {
+	Package      string
+	Version      string
+	Architecture string
+	Filename     string
+	SHA256       string
+	Size         int64
+
+	// component is the component this package was resolved from, not part of the Packages
+	// stanza itself; threaded through so the download stage knows which IncludeDeb to call.
+	component string
+}
+
+// MirrorUpstream fetches dists/<cfg.Suite>/InRelease from cfg.BaseURL, verifies its clearsign
+// against cfg.TrustedKeyring, resolves the per-component/architecture Packages index by the
+// SHA256 Release records for it, and then -- unless cfg.DryRun is set, in which case it only logs
+// each package it would fetch -- downloads each referenced .deb into a content-addressed cache
+// under cfg.CacheDir and materializes each into this Repo via IncludeDeb, exactly as a locally
+// built .deb would be.
+func (repo *Repo) MirrorUpstream(ctx context.Context, cfg MirrorConfig) error {
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(cfg.TrustedKeyring))
+	if err != nil {
+		return fmt.Errorf("parsing trusted keyring: %w", err)
+	}
+
+	inRelease, err := fetchURL(ctx, fmt.Sprintf("%s/dists/%s/InRelease", cfg.BaseURL, cfg.Suite))
+	if err != nil {
+		return fmt.Errorf("fetching InRelease: %w", err)
+	}
+
+	release, err := verifyClearsignedRelease(inRelease, keyring)
+	if err != nil {
+		return fmt.Errorf("verifying InRelease: %w", err)
+	}
+
+	shaEntries := parseSHA256Section(release)
+
+	var toFetch []upstreamPackage
+	for _, component := range cfg.Components {
+		for _, arch := range cfg.Architectures {
+			index, err := fetchPackagesIndex(ctx, cfg.BaseURL, cfg.Suite, component, arch, shaEntries)
+			if err != nil {
+				return fmt.Errorf("fetching Packages index for %s/%s: %w", component, arch, err)
+			}
+			for _, pkg := range parsePackagesIndex(index) {
+				pkg.component = component
+				toFetch = append(toFetch, pkg)
+			}
+		}
+	}
+
+	logrus.WithField("count", len(toFetch)).Info("Resolved upstream packages to mirror")
+
+	if cfg.DryRun {
+		for _, pkg := range toFetch {
+			logrus.Infof("Would fetch %s (component=%s, %d bytes, sha256 %s)", pkg.Filename, pkg.component, pkg.Size, pkg.SHA256)
+		}
+		return nil
+	}
+
+	concurrency := cfg.Concurrency
+	if concurrency <= 0 {
+		concurrency = 4
+	}
+
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+
+	for _, pkg := range toFetch {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(pkg upstreamPackage) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			cached, err := downloadToCache(ctx, cfg.BaseURL, cfg.CacheDir, pkg)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("downloading %s: %w", pkg.Filename, err))
+				mu.Unlock()
+				return
+			}
+
+			// IncludeDeb updates the Repo's unsynchronized state, so includes run one at a time.
+			mu.Lock()
+			defer mu.Unlock()
+			if err := repo.IncludeDeb(pkg.component, cached); err != nil {
+				errs = append(errs, fmt.Errorf("including %s: %w", pkg.Filename, err))
+			}
+		}(pkg)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// fetchURL GETs url and returns its body, erroring on anything but a 200 response.
+func fetchURL(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request for %s: %w", url, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: unexpected status %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// verifyClearsignedRelease checks a clearsigned InRelease's signature against keyring and returns
+// the signed content (the plain Release file) if it verifies.
+func verifyClearsignedRelease(raw []byte, keyring openpgp.EntityList) ([]byte, error) {
+	block, _ := clearsign.Decode(raw)
+	if block == nil {
+		return nil, fmt.Errorf("InRelease is not a valid clearsigned message")
+	}
+	if _, err := openpgp.CheckDetachedSignature(keyring, bytes.NewReader(block.Bytes), block.ArmoredSignature.Body, nil); err != nil {
+		return nil, fmt.Errorf("signature does not verify against the trusted keyring: %w", err)
+	}
+	return block.Bytes, nil
+}
+
+// parseSHA256Section extracts a Release file's "SHA256:" index section (written by
+// writeHashSection in the same " <hash> <size> <path>" form this parses) into a map keyed by
+// path, so callers can look up the expected checksum for a dists/<suite>/<path> file by name.
+func parseSHA256Section(release []byte) map[string]releaseFileEntry {
+	entries := map[string]releaseFileEntry{}
+	inSection := false
+	for _, line := range strings.Split(string(release), "\n") {
+		if line == "SHA256:" {
+			inSection = true
+			continue
+		}
+		if !inSection {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			inSection = false
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		entries[fields[2]] = releaseFileEntry{path: fields[2], size: size, sha256: fields[0]}
+	}
+	return entries
+}
+
+// packagesIndexVariants are the <component>/binary-<arch>/Packages* names fetchPackagesIndex
+// tries, in order of preference, along with how to turn their bytes into the plain stanza text.
+var packagesIndexVariants = []struct {
+	suffix     string
+	decompress func([]byte) ([]byte, error)
+}{
+	{"Packages.gz", decompressGzip},
+	{"Packages.xz", decompressXz},
+	{"Packages", func(data []byte) ([]byte, error) { return data, nil }},
+}
+
+// fetchPackagesIndex downloads and decompresses the first available Packages index for
+// component/arch, verifying it against the SHA256 Release recorded in shaEntries.
+func fetchPackagesIndex(ctx context.Context, baseURL, suite, component, arch string, shaEntries map[string]releaseFileEntry) ([]byte, error) {
+	dir := fmt.Sprintf("%s/binary-%s", component, arch)
+
+	var lastErr error
+	for _, variant := range packagesIndexVariants {
+		relPath := fmt.Sprintf("%s/%s", dir, variant.suffix)
+		entry, ok := shaEntries[relPath]
+		if !ok {
+			continue
+		}
+
+		data, err := fetchURL(ctx, fmt.Sprintf("%s/dists/%s/%s", baseURL, suite, relPath))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if sum := fmt.Sprintf("%x", sha256.Sum256(data)); sum != entry.sha256 {
+			lastErr = fmt.Errorf("checksum mismatch for %s: got %s, want %s", relPath, sum, entry.sha256)
+			continue
+		}
+
+		return variant.decompress(data)
+	}
+
+	if lastErr != nil {
+		return nil, lastErr
+	}
+	return nil, fmt.Errorf("Release has no Packages index for %s", dir)
+}
+
+func decompressGzip(data []byte) ([]byte, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("opening gzip Packages index: %w", err)
+	}
+	defer func() { _ = gz.Close() }()
+	return io.ReadAll(gz)
+}
+
+func decompressXz(data []byte) ([]byte, error) {
+	xr, err := xz.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("opening xz Packages index: %w", err)
+	}
+	return io.ReadAll(xr)
+}
+
+// parsePackagesIndex splits a Packages index (stanzas separated by a blank line, same as a .deb's
+// control stanza format) into one upstreamPackage per stanza.
+func parsePackagesIndex(data []byte) []upstreamPackage {
+	var pkgs []upstreamPackage
+	for _, stanza := range strings.Split(string(data), "\n\n") {
+		if strings.TrimSpace(stanza) == "" {
+			continue
+		}
+		fields := parseControlStanza(stanza)
+		size, _ := strconv.ParseInt(fields["Size"], 10, 64)
+		pkgs = append(pkgs, upstreamPackage{
+			Package:      fields["Package"],
+			Version:      fields["Version"],
+			Architecture: fields["Architecture"],
+			Filename:     fields["Filename"],
+			SHA256:       fields["SHA256"],
+			Size:         size,
+		})
+	}
+	return pkgs
+}
+
+// cachePath returns the content-addressed path a package with the given SHA256 is cached under.
+// sha256Sum must be at least two characters; callers that got it from a Packages index should
+// validate it first, since a malformed stanza can leave it empty.
+func cachePath(cacheDir, sha256Sum string) string {
+	return filepath.Join(cacheDir, sha256Sum[:2], sha256Sum+".deb")
+}
+
+// downloadToCache fetches pkg's .deb from baseURL into the content-addressed cache under
+// cacheDir, resuming any previous partial download (tracked via a ".part" sibling file) with an
+// HTTP Range request, and verifies the completed file's SHA256 before renaming it into place.
+func downloadToCache(ctx context.Context, baseURL, cacheDir string, pkg upstreamPackage) (string, error) {
+	if len(pkg.SHA256) < 2 {
+		return "", fmt.Errorf("package %s has no SHA256 checksum in the Packages index", pkg.Filename)
+	}
+	dest := cachePath(cacheDir, pkg.SHA256)
+	if info, err := os.Stat(dest); err == nil && info.Size() == pkg.Size {
+		return dest, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), utils.DirPerms); err != nil {
+		return "", fmt.Errorf("creating cache directory for %s: %w", pkg.Filename, err)
+	}
+
+	partial := dest + ".part"
+	var startOffset int64
+	if info, err := os.Stat(partial); err == nil {
+		startOffset = info.Size()
+	}
+
+	url := fmt.Sprintf("%s/%s", baseURL, pkg.Filename)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", fmt.Errorf("building request for %s: %w", url, err)
+	}
+	if startOffset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", startOffset))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+	case http.StatusOK:
+		// The server ignored our Range request and is sending the whole file; truncate
+		// whatever partial data we had and start over.
+		flags |= os.O_TRUNC
+	default:
+		return "", fmt.Errorf("fetching %s: unexpected status %s", url, resp.Status)
+	}
+
+	f, err := os.OpenFile(partial, flags, 0o644)
+	if err != nil {
+		return "", fmt.Errorf("opening %s: %w", partial, err)
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		_ = f.Close()
+		return "", fmt.Errorf("downloading %s: %w", url, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", fmt.Errorf("closing %s: %w", partial, err)
+	}
+
+	data, err := os.ReadFile(partial)
+	if err != nil {
+		return "", fmt.Errorf("reading %s: %w", partial, err)
+	}
+	if sum := fmt.Sprintf("%x", sha256.Sum256(data)); sum != pkg.SHA256 {
+		return "", fmt.Errorf("checksum mismatch for %s: got %s, want %s", pkg.Filename, sum, pkg.SHA256)
+	}
+
+	if err := os.Rename(partial, dest); err != nil {
+		return "", fmt.Errorf("finalizing %s: %w", dest, err)
+	}
+
+	return dest, nil
+}
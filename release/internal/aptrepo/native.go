@@ -0,0 +1,184 @@
+package aptrepo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/ulikunitz/xz"
+
+	"github.com/projectcalico/calico/release/internal/utils"
+)
+
+// nativePackages tracks, per suite/component, the ControlFields of every package added via the
+// native backend, in the order they were added. This is what the native index writer iterates
+// over to produce Packages/Packages.gz/Packages.xz.
+type nativePackages struct {
+	bySuiteComponent map[string][]ControlFields
+}
+
+// nativeIncludeDeb implements IncludeDeb without shelling out to reprepro: it parses the .deb as
+// an ar archive, pulls the control stanza and checksums out of it, copies the package into
+// pool/<component>/<letter>/<source>/, and records it for later index generation.
+func (repo *Repo) nativeIncludeDeb(component, debFile string) error {
+	cf, err := readControlStanza(debFile)
+	if err != nil {
+		return fmt.Errorf("reading control info from %s: %w", debFile, err)
+	}
+
+	source := cf.Get("Source")
+	if source == "" {
+		source = cf.Get("Package")
+	}
+	firstLetter := source[:1]
+	if strings.HasPrefix(source, "lib") && len(source) > 3 {
+		firstLetter = source[:4]
+	}
+
+	poolDir := filepath.Join(repo.OutputDirectory, "pool", component, firstLetter, source)
+	if err := os.MkdirAll(poolDir, utils.DirPerms); err != nil {
+		return fmt.Errorf("creating pool directory %s: %w", poolDir, err)
+	}
+
+	dest := filepath.Join(poolDir, filepath.Base(debFile))
+	if err := utils.CopyFile(debFile, dest); err != nil {
+		return fmt.Errorf("copying %s into pool: %w", debFile, err)
+	}
+
+	cf.Filename, err = filepath.Rel(repo.OutputDirectory, dest)
+	if err != nil {
+		return fmt.Errorf("computing relative pool path for %s: %w", dest, err)
+	}
+
+	if repo.nativePkgs == nil {
+		repo.nativePkgs = &nativePackages{bySuiteComponent: map[string][]ControlFields{}}
+	}
+	key := component
+	repo.nativePkgs.bySuiteComponent[key] = append(repo.nativePkgs.bySuiteComponent[key], cf)
+
+	return nil
+}
+
+// WriteNativeIndices generates dists/<component>/main/binary-<arch>/Packages{,.gz,.xz} for every
+// component that has had packages added via the native backend, followed by a signed
+// dists/<component>/Release (plus InRelease and Release.gpg). It is the native-backend
+// counterpart of reprepro's "export" step.
+func (repo *Repo) WriteNativeIndices() error {
+	if repo.nativePkgs == nil {
+		return nil
+	}
+
+	for component, pkgs := range repo.nativePkgs.bySuiteComponent {
+		byArch := map[string][]ControlFields{}
+		for _, p := range pkgs {
+			arch := p.Get("Architecture")
+			byArch[arch] = append(byArch[arch], p)
+		}
+
+		var releaseEntries []releaseFileEntry
+		for arch, archPkgs := range byArch {
+			sort.Slice(archPkgs, func(i, j int) bool {
+				return archPkgs[i].Get("Package") < archPkgs[j].Get("Package")
+			})
+
+			relDir := filepath.Join("main", fmt.Sprintf("binary-%s", arch))
+			indexDir := filepath.Join(repo.OutputDirectory, "dists", component, relDir)
+			if err := os.MkdirAll(indexDir, utils.DirPerms); err != nil {
+				return fmt.Errorf("creating index dir %s: %w", indexDir, err)
+			}
+
+			var packagesBuf bytes.Buffer
+			for _, p := range archPkgs {
+				writePackagesStanza(&packagesBuf, p)
+			}
+
+			if err := os.WriteFile(filepath.Join(indexDir, "Packages"), packagesBuf.Bytes(), 0o644); err != nil {
+				return fmt.Errorf("writing Packages for %s/%s: %w", component, arch, err)
+			}
+			releaseEntries = append(releaseEntries, describeReleaseFile(filepath.Join(relDir, "Packages"), packagesBuf.Bytes()))
+
+			gzData, err := gzipBytes(packagesBuf.Bytes())
+			if err != nil {
+				return fmt.Errorf("compressing Packages.gz for %s/%s: %w", component, arch, err)
+			}
+			if err := os.WriteFile(filepath.Join(indexDir, "Packages.gz"), gzData, 0o644); err != nil {
+				return fmt.Errorf("writing Packages.gz for %s/%s: %w", component, arch, err)
+			}
+			releaseEntries = append(releaseEntries, describeReleaseFile(filepath.Join(relDir, "Packages.gz"), gzData))
+
+			xzData, err := xzBytes(packagesBuf.Bytes())
+			if err != nil {
+				return fmt.Errorf("compressing Packages.xz for %s/%s: %w", component, arch, err)
+			}
+			if err := os.WriteFile(filepath.Join(indexDir, "Packages.xz"), xzData, 0o644); err != nil {
+				return fmt.Errorf("writing Packages.xz for %s/%s: %w", component, arch, err)
+			}
+			releaseEntries = append(releaseEntries, describeReleaseFile(filepath.Join(relDir, "Packages.xz"), xzData))
+		}
+
+		if err := repo.writeNativeRelease(component, releaseEntries); err != nil {
+			return fmt.Errorf("writing Release metadata for %s: %w", component, err)
+		}
+	}
+
+	return nil
+}
+
+// writePackagesStanza appends one package's stanza (the control fields plus the
+// Filename/Size/checksums that only make sense once the file is laid out in the pool) to buf.
+func writePackagesStanza(buf *bytes.Buffer, cf ControlFields) {
+	order := []string{"Package", "Source", "Version", "Architecture", "Maintainer", "Installed-Size", "Depends", "Section", "Priority", "Description"}
+	seen := map[string]bool{}
+	// parseControlStanza joins continuation lines with "\n"; indent them again on the way out.
+	for _, k := range order {
+		if v, ok := cf.Fields[k]; ok {
+			fmt.Fprintf(buf, "%s: %s\n", k, strings.ReplaceAll(v, "\n", "\n "))
+			seen[k] = true
+		}
+	}
+	for k, v := range cf.Fields {
+		if seen[k] {
+			continue
+		}
+		fmt.Fprintf(buf, "%s: %s\n", k, strings.ReplaceAll(v, "\n", "\n "))
+	}
+	fmt.Fprintf(buf, "Filename: %s\n", cf.Filename)
+	fmt.Fprintf(buf, "Size: %d\n", cf.Size)
+	fmt.Fprintf(buf, "MD5sum: %s\n", cf.MD5Sum)
+	fmt.Fprintf(buf, "SHA1: %s\n", cf.SHA1)
+	fmt.Fprintf(buf, "SHA256: %s\n", cf.SHA256)
+	buf.WriteString("\n")
+}
+
+// gzipBytes gzip-compresses data in one shot, for the Packages.gz index.
+func gzipBytes(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	gz := gzip.NewWriter(&out)
+	if _, err := gz.Write(data); err != nil {
+		return nil, fmt.Errorf("gzip-compressing: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return nil, fmt.Errorf("gzip-compressing: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// xzBytes xz-compresses data in one shot, for the Packages.xz index.
+func xzBytes(data []byte) ([]byte, error) {
+	var out bytes.Buffer
+	xw, err := xz.NewWriter(&out)
+	if err != nil {
+		return nil, fmt.Errorf("opening xz writer: %w", err)
+	}
+	if _, err := xw.Write(data); err != nil {
+		return nil, fmt.Errorf("xz-compressing: %w", err)
+	}
+	if err := xw.Close(); err != nil {
+		return nil, fmt.Errorf("xz-compressing: %w", err)
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,107 @@
+package aptrepo
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	spdxlicense "github.com/github/go-spdx/v2/spdxlicense"
+)
+
+// PackagePolicy gates which .deb packages IncludeDeb/RecursiveAddDebsFromDirectories are willing
+// to accept. Any zero-value field is treated as "no constraint".
+type PackagePolicy struct {
+	// AllowedSPDXLicenses is the set of SPDX license identifiers a package's License field is
+	// allowed to declare; when it is non-empty, a package whose control stanza has no License
+	// field is rejected. Validated against the canonical SPDX license list.
+	AllowedSPDXLicenses []string
+	// RequiredMaintainerRegex, if set, must match the control stanza's Maintainer field.
+	RequiredMaintainerRegex *regexp.Regexp
+	// RequiredOriginRegex, if set, must match the control stanza's Origin field (or Vendor, for
+	// packages that don't set Origin).
+	RequiredOriginRegex *regexp.Regexp
+}
+
+// PolicyViolation is returned by checkPackagePolicy and lists every field of the package's
+// control stanza that failed the configured PackagePolicy, so CI can report everything wrong with
+// a package in one pass instead of failing on the first check.
+type PolicyViolation struct {
+	Package string
+	Reasons []string
+}
+
+func (e *PolicyViolation) Error() string {
+	return fmt.Sprintf("package %s violates package policy: %s", e.Package, strings.Join(e.Reasons, "; "))
+}
+
+// checkPackagePolicy extracts the control stanza from debFile and evaluates it against
+// repo.PackagePolicy (a zero-value PackagePolicy accepts everything).
+func (repo *Repo) checkPackagePolicy(debFile string) error {
+	policy := repo.PackagePolicy
+	if len(policy.AllowedSPDXLicenses) == 0 && policy.RequiredMaintainerRegex == nil && policy.RequiredOriginRegex == nil {
+		return nil
+	}
+
+	cf, err := readControlStanza(debFile)
+	if err != nil {
+		return fmt.Errorf("reading control info from %s for policy check: %w", debFile, err)
+	}
+
+	var reasons []string
+
+	if len(policy.AllowedSPDXLicenses) > 0 {
+		license := cf.Get("License")
+		if license == "" {
+			reasons = append(reasons, "no License field present in control stanza")
+		} else if err := validateSPDXLicense(license, policy.AllowedSPDXLicenses); err != nil {
+			reasons = append(reasons, err.Error())
+		}
+	}
+
+	if policy.RequiredMaintainerRegex != nil {
+		maintainer := cf.Get("Maintainer")
+		if !policy.RequiredMaintainerRegex.MatchString(maintainer) {
+			reasons = append(reasons, fmt.Sprintf("maintainer %q does not match required pattern %s", maintainer, policy.RequiredMaintainerRegex.String()))
+		}
+	}
+
+	if policy.RequiredOriginRegex != nil {
+		origin := cf.Get("Origin")
+		if origin == "" {
+			origin = cf.Get("Vendor")
+		}
+		if !policy.RequiredOriginRegex.MatchString(origin) {
+			reasons = append(reasons, fmt.Sprintf("origin %q does not match required pattern %s", origin, policy.RequiredOriginRegex.String()))
+		}
+	}
+
+	if len(reasons) > 0 {
+		return &PolicyViolation{Package: cf.Get("Package"), Reasons: reasons}
+	}
+	return nil
+}
+
+// validateSPDXLicense checks that every license identifier referenced in a (possibly compound,
+// e.g. "Apache-2.0 OR MIT") license expression is both a recognized SPDX identifier and present in
+// allowed.
+func validateSPDXLicense(licenseExpr string, allowed []string) error {
+	allowedSet := make(map[string]bool, len(allowed))
+	for _, l := range allowed {
+		allowedSet[l] = true
+	}
+
+	for _, tok := range strings.Fields(licenseExpr) {
+		switch tok {
+		case "AND", "OR", "WITH", "(", ")":
+			continue
+		}
+		id := strings.Trim(tok, "()")
+		if _, ok := spdxlicense.GetLicenseID(id); !ok {
+			return fmt.Errorf("license %q is not a recognized SPDX identifier", id)
+		}
+		if !allowedSet[id] {
+			return fmt.Errorf("license %q is not in the allowed license list", id)
+		}
+	}
+	return nil
+}
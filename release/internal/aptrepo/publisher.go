@@ -0,0 +1,215 @@
+package aptrepo
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Publisher abstracts the storage target that a built repo tree (dists/ and pool/) is shipped
+// to, so Repo.Publish can push to a local path, an S3 bucket, a GCS bucket, or anything else that
+// implements this interface, rather than assuming the output directory is always local disk.
+type Publisher interface {
+	// Put uploads the content read from r to relPath (a path relative to the repo root, e.g.
+	// "pool/main/c/calico/calico_1.0_amd64.deb").
+	Put(ctx context.Context, relPath string, r io.Reader, contentType string) error
+	// Delete removes the object at relPath, if present.
+	Delete(ctx context.Context, relPath string) error
+	// List returns every object path currently stored under the given prefix.
+	List(ctx context.Context, prefix string) ([]string, error)
+}
+
+// LocalPublisher is a Publisher that writes to a local filesystem directory. It reproduces the
+// repo's current behavior of treating OutputDirectory as the final destination.
+type LocalPublisher struct {
+	// RootDir is the local directory objects are published under.
+	RootDir string
+}
+
+func NewLocalPublisher(rootDir string) *LocalPublisher {
+	return &LocalPublisher{RootDir: rootDir}
+}
+
+func (p *LocalPublisher) Put(_ context.Context, relPath string, r io.Reader, _ string) error {
+	dst := filepath.Join(p.RootDir, relPath)
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return fmt.Errorf("creating directory for %s: %w", relPath, err)
+	}
+	f, err := os.Create(dst)
+	if err != nil {
+		return fmt.Errorf("creating %s: %w", dst, err)
+	}
+	defer func() { _ = f.Close() }()
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("writing %s: %w", dst, err)
+	}
+	return nil
+}
+
+func (p *LocalPublisher) Delete(_ context.Context, relPath string) error {
+	err := os.Remove(filepath.Join(p.RootDir, relPath))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("deleting %s: %w", relPath, err)
+	}
+	return nil
+}
+
+func (p *LocalPublisher) List(_ context.Context, prefix string) ([]string, error) {
+	var out []string
+	root := filepath.Join(p.RootDir, prefix)
+	err := filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(p.RootDir, path)
+		if err != nil {
+			return err
+		}
+		out = append(out, rel)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing %s: %w", prefix, err)
+	}
+	return out, nil
+}
+
+// contentTypeFor returns a best-effort content type for a repo file, based on extension, falling
+// back to "application/octet-stream".
+func contentTypeFor(relPath string) string {
+	if ct := mime.TypeByExtension(filepath.Ext(relPath)); ct != "" {
+		return ct
+	}
+	return "application/octet-stream"
+}
+
+// Publish walks OutputDirectory and uploads every file to the given Publisher, skipping files
+// whose content hash matches the one this Repo recorded when it last uploaded the same path,
+// uploading Release/InRelease/Release.gpg last (so a client never sees metadata referencing
+// objects that haven't landed yet), and finally deleting every remote object that has no file
+// under OutputDirectory. The recorded hashes live only in memory, so the first Publish of a Repo
+// uploads every file; remote contents are not read, and Release is not parsed to decide what to
+// prune.
+func (repo *Repo) Publish(ctx context.Context, p Publisher) error {
+	existing, err := p.List(ctx, "")
+	if err != nil {
+		return fmt.Errorf("listing existing published objects: %w", err)
+	}
+	existingSet := make(map[string]bool, len(existing))
+	for _, e := range existing {
+		existingSet[e] = true
+	}
+
+	var releaseFiles []string
+	var otherFiles []string
+	var published []string
+
+	err = filepath.WalkDir(repo.OutputDirectory, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(repo.OutputDirectory, path)
+		if err != nil {
+			return err
+		}
+		base := filepath.Base(rel)
+		if base == "Release" || base == "InRelease" || base == "Release.gpg" {
+			releaseFiles = append(releaseFiles, rel)
+		} else {
+			otherFiles = append(otherFiles, rel)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("walking output directory %s: %w", repo.OutputDirectory, err)
+	}
+
+	for _, rel := range otherFiles {
+		uploaded, err := repo.publishOne(ctx, p, rel)
+		if err != nil {
+			return err
+		}
+		if uploaded {
+			published = append(published, rel)
+		}
+	}
+	for _, rel := range releaseFiles {
+		uploaded, err := repo.publishOne(ctx, p, rel)
+		if err != nil {
+			return err
+		}
+		if uploaded {
+			published = append(published, rel)
+		}
+	}
+
+	keep := make(map[string]bool, len(otherFiles)+len(releaseFiles))
+	for _, rel := range otherFiles {
+		keep[rel] = true
+	}
+	for _, rel := range releaseFiles {
+		keep[rel] = true
+	}
+	for _, rel := range existing {
+		if !keep[rel] {
+			if err := p.Delete(ctx, rel); err != nil {
+				return fmt.Errorf("pruning stale object %s: %w", rel, err)
+			}
+			logrus.WithField("object", rel).Info("Pruned object no longer referenced by published repo")
+		}
+	}
+
+	logrus.WithField("count", len(published)).Info("Published apt repo objects")
+	return nil
+}
+
+// publishOne uploads a single relative path from OutputDirectory to the Publisher, skipping the
+// upload if the content hash matches the one recorded in publishedHashes at this Repo's last
+// upload of relPath.
+func (repo *Repo) publishOne(ctx context.Context, p Publisher, relPath string) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(repo.OutputDirectory, relPath))
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", relPath, err)
+	}
+
+	sum := sha256.Sum256(data)
+	hexSum := hex.EncodeToString(sum[:])
+	if repo.publishedHashes != nil && repo.publishedHashes[relPath] == hexSum {
+		return false, nil
+	}
+
+	if err := p.Put(ctx, relPath, bytes.NewReader(data), contentTypeFor(relPath)); err != nil {
+		return false, fmt.Errorf("uploading %s: %w", relPath, err)
+	}
+
+	if repo.publishedHashes == nil {
+		repo.publishedHashes = map[string]string{}
+	}
+	repo.publishedHashes[relPath] = hexSum
+
+	return true, nil
+}
+
+// stripLeadingSlash is a small helper used when normalizing object keys for remote backends that
+// don't want a leading slash (S3, GCS).
+func stripLeadingSlash(p string) string {
+	return strings.TrimPrefix(p, "/")
+}
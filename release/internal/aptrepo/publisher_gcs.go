@@ -0,0 +1,77 @@
+package aptrepo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// GCSPublisher publishes a repo tree to a Google Cloud Storage bucket, optionally under a key
+// prefix.
+type GCSPublisher struct {
+	Client *storage.Client
+	Bucket string
+	Prefix string
+}
+
+func NewGCSPublisher(client *storage.Client, bucket, prefix string) *GCSPublisher {
+	return &GCSPublisher{Client: client, Bucket: bucket, Prefix: strings.Trim(prefix, "/")}
+}
+
+func (p *GCSPublisher) object(relPath string) string {
+	if p.Prefix == "" {
+		return stripLeadingSlash(relPath)
+	}
+	return path.Join(p.Prefix, stripLeadingSlash(relPath))
+}
+
+func (p *GCSPublisher) Put(ctx context.Context, relPath string, r io.Reader, contentType string) error {
+	obj := p.Client.Bucket(p.Bucket).Object(p.object(relPath))
+	w := obj.NewWriter(ctx)
+	w.ContentType = contentType
+	if _, err := io.Copy(w, r); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("uploading gs://%s/%s: %w", p.Bucket, p.object(relPath), err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("finalizing upload of gs://%s/%s: %w", p.Bucket, p.object(relPath), err)
+	}
+	return nil
+}
+
+func (p *GCSPublisher) Delete(ctx context.Context, relPath string) error {
+	err := p.Client.Bucket(p.Bucket).Object(p.object(relPath)).Delete(ctx)
+	if err != nil && err != storage.ErrObjectNotExist {
+		return fmt.Errorf("deleting gs://%s/%s: %w", p.Bucket, p.object(relPath), err)
+	}
+	return nil
+}
+
+func (p *GCSPublisher) List(ctx context.Context, prefix string) ([]string, error) {
+	var out []string
+	listPrefix := p.object(prefix)
+	it := p.Client.Bucket(p.Bucket).Objects(ctx, &storage.Query{Prefix: listPrefix})
+	for {
+		attrs, err := it.Next()
+		if err == storage.ErrBucketNotExist {
+			return nil, nil
+		}
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("listing gs://%s/%s: %w", p.Bucket, listPrefix, err)
+		}
+		name := attrs.Name
+		if p.Prefix != "" {
+			name = strings.TrimPrefix(name, p.Prefix+"/")
+		}
+		out = append(out, name)
+	}
+	return out, nil
+}
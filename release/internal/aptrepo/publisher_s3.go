@@ -0,0 +1,78 @@
+package aptrepo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Publisher publishes a repo tree to an S3 (or S3-compatible) bucket, optionally under a
+// key prefix, e.g. for publishing several products/releases from the same bucket.
+type S3Publisher struct {
+	Client *s3.Client
+	Bucket string
+	Prefix string
+}
+
+func NewS3Publisher(client *s3.Client, bucket, prefix string) *S3Publisher {
+	return &S3Publisher{Client: client, Bucket: bucket, Prefix: strings.Trim(prefix, "/")}
+}
+
+func (p *S3Publisher) key(relPath string) string {
+	if p.Prefix == "" {
+		return stripLeadingSlash(relPath)
+	}
+	return path.Join(p.Prefix, stripLeadingSlash(relPath))
+}
+
+func (p *S3Publisher) Put(ctx context.Context, relPath string, r io.Reader, contentType string) error {
+	_, err := p.Client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(p.Bucket),
+		Key:         aws.String(p.key(relPath)),
+		Body:        r,
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("uploading s3://%s/%s: %w", p.Bucket, p.key(relPath), err)
+	}
+	return nil
+}
+
+func (p *S3Publisher) Delete(ctx context.Context, relPath string) error {
+	_, err := p.Client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(p.Bucket),
+		Key:    aws.String(p.key(relPath)),
+	})
+	if err != nil {
+		return fmt.Errorf("deleting s3://%s/%s: %w", p.Bucket, p.key(relPath), err)
+	}
+	return nil
+}
+
+func (p *S3Publisher) List(ctx context.Context, prefix string) ([]string, error) {
+	var out []string
+	listPrefix := p.key(prefix)
+	paginator := s3.NewListObjectsV2Paginator(p.Client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(p.Bucket),
+		Prefix: aws.String(listPrefix),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("listing s3://%s/%s: %w", p.Bucket, listPrefix, err)
+		}
+		for _, obj := range page.Contents {
+			key := aws.ToString(obj.Key)
+			if p.Prefix != "" {
+				key = strings.TrimPrefix(key, p.Prefix+"/")
+			}
+			out = append(out, key)
+		}
+	}
+	return out, nil
+}
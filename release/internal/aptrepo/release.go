@@ -0,0 +1,195 @@
+package aptrepo
+
+import (
+	"bytes"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+
+	"github.com/projectcalico/calico/release/internal/utils"
+)
+
+// releaseFileEntry is one line of a Release file's MD5Sum/SHA1/SHA256 index sections: a generated
+// index file's checksum, size, and path relative to dists/<component>/.
+type releaseFileEntry struct {
+	path   string
+	size   int64
+	md5    string
+	sha1   string
+	sha256 string
+}
+
+// describeReleaseFile computes the releaseFileEntry for a generated index file, given its
+// already-serialised contents and its path relative to dists/<component>/.
+func describeReleaseFile(relPath string, data []byte) releaseFileEntry {
+	return releaseFileEntry{
+		path:   relPath,
+		size:   int64(len(data)),
+		md5:    fmt.Sprintf("%x", md5.Sum(data)),
+		sha1:   fmt.Sprintf("%x", sha1.Sum(data)),
+		sha256: fmt.Sprintf("%x", sha256.Sum256(data)),
+	}
+}
+
+// suiteName returns the 'Suite' field to use for component, honouring the per-component override
+// in RepoConfig.Suite and falling back to the component name itself -- the same rule
+// writePinPreferenceFile and reprepro-conf.gotmpl already apply for the reprepro backend.
+func (repo *Repo) suiteName(component string) string {
+	if override, ok := repo.Config.Suite[component]; ok && override != "" {
+		return override
+	}
+	return component
+}
+
+// codename returns the 'Codename' field to use for component: the repo-wide Codename override if
+// configured, else the component name itself, mirroring reprepro-conf.gotmpl.
+func (repo *Repo) codename(component string) string {
+	if repo.Config.Codename != "" {
+		return repo.Config.Codename
+	}
+	return component
+}
+
+// writeNativeRelease generates dists/<component>/Release, its clearsigned InRelease counterpart,
+// and a detached Release.gpg, indexing entries -- every Packages/Packages.gz/Packages.xz file
+// WriteNativeIndices has just written underneath dists/<component>/. It is the native backend's
+// counterpart of reprepro's own "export" step, which normally does this (and the signing) for us.
+func (repo *Repo) writeNativeRelease(component string, entries []releaseFileEntry) error {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].path < entries[j].path })
+
+	componentDir := filepath.Join(repo.OutputDirectory, "dists", component)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "Origin: %s\n", repo.Config.Origin)
+	fmt.Fprintf(&buf, "Label: %s\n", repo.Config.Label)
+	fmt.Fprintf(&buf, "Suite: %s\n", repo.suiteName(component))
+	fmt.Fprintf(&buf, "Codename: %s\n", repo.codename(component))
+	fmt.Fprintf(&buf, "Architectures: %s\n", strings.Join(repo.Config.Architectures, " "))
+	fmt.Fprintf(&buf, "Components: main\n")
+	fmt.Fprintf(&buf, "Description: %s apt repository (%s)\n", repo.Config.ProductName, component)
+	if repo.Config.NotAutomatic {
+		buf.WriteString("NotAutomatic: yes\n")
+	}
+	if repo.Config.ButAutomaticUpgrades {
+		buf.WriteString("ButAutomaticUpgrades: yes\n")
+	}
+	now := time.Now().UTC()
+	fmt.Fprintf(&buf, "Date: %s\n", now.Format(time.RFC1123Z))
+	if repo.Config.ValidUntilDuration > 0 {
+		fmt.Fprintf(&buf, "Valid-Until: %s\n", now.Add(repo.Config.ValidUntilDuration).Format(time.RFC1123Z))
+	}
+
+	writeHashSection(&buf, "MD5Sum", entries, func(e releaseFileEntry) string { return e.md5 })
+	writeHashSection(&buf, "SHA1", entries, func(e releaseFileEntry) string { return e.sha1 })
+	writeHashSection(&buf, "SHA256", entries, func(e releaseFileEntry) string { return e.sha256 })
+
+	if err := os.WriteFile(filepath.Join(componentDir, "Release"), buf.Bytes(), 0o644); err != nil {
+		return fmt.Errorf("writing Release for %s: %w", component, err)
+	}
+
+	keyring, err := repo.loadSigningKeyring()
+	if err != nil {
+		return fmt.Errorf("loading signing key %s: %w", repo.Config.GPGKeyID, err)
+	}
+
+	inRelease, err := signReleaseClearsign(buf.Bytes(), repo.Config.GPGKeyID, keyring)
+	if err != nil {
+		return fmt.Errorf("signing InRelease for %s: %w", component, err)
+	}
+	if err := os.WriteFile(filepath.Join(componentDir, "InRelease"), inRelease, 0o644); err != nil {
+		return fmt.Errorf("writing InRelease for %s: %w", component, err)
+	}
+
+	detached, err := signReleaseDetached(buf.Bytes(), repo.Config.GPGKeyID, keyring)
+	if err != nil {
+		return fmt.Errorf("signing Release.gpg for %s: %w", component, err)
+	}
+	if err := os.WriteFile(filepath.Join(componentDir, "Release.gpg"), detached, 0o644); err != nil {
+		return fmt.Errorf("writing Release.gpg for %s: %w", component, err)
+	}
+
+	return nil
+}
+
+// writeHashSection appends one of a Release file's per-algorithm index sections: a "<Header>:"
+// line followed by one " <hash> <size> <path>" line per entry, per the Release file format.
+func writeHashSection(buf *bytes.Buffer, header string, entries []releaseFileEntry, hash func(releaseFileEntry) string) {
+	fmt.Fprintf(buf, "%s:\n", header)
+	for _, e := range entries {
+		fmt.Fprintf(buf, " %s %16d %s\n", hash(e), e.size, e.path)
+	}
+}
+
+// loadSigningKeyring exports the secret key material for repo.Config.GPGKeyID from the local gpg
+// keyring and parses it, so the native backend can sign without shelling out to `gpg` itself for
+// the actual signing step (only for the export, same as GetGPGPubKey already does for the public
+// half).
+func (repo *Repo) loadSigningKeyring() (openpgp.EntityList, error) {
+	armored, err := utils.GetGPGSecretKey(repo.Config.GPGKeyID)
+	if err != nil {
+		return nil, fmt.Errorf("exporting signing key: %w", err)
+	}
+	keyring, err := openpgp.ReadArmoredKeyRing(strings.NewReader(armored))
+	if err != nil {
+		return nil, fmt.Errorf("parsing signing key: %w", err)
+	}
+	return keyring, nil
+}
+
+// findSigningEntity returns the keyring entity whose primary key matches keyID, or an error if
+// none does.
+func findSigningEntity(keyID string, keyring openpgp.EntityList) (*openpgp.Entity, error) {
+	for _, e := range keyring {
+		if e.PrimaryKey != nil && e.PrimaryKey.KeyIdString() == keyID {
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("no key with ID %s found in keyring", keyID)
+}
+
+// signReleaseClearsign produces the clearsign-formatted InRelease file: the Release file's
+// contents followed by an inline ASCII-armored signature, the format `gpg --clearsign` produces
+// and the one apt expects when it fetches InRelease instead of the separate Release/Release.gpg.
+func signReleaseClearsign(releaseData []byte, keyID string, keyring openpgp.EntityList) ([]byte, error) {
+	signer, err := findSigningEntity(keyID, keyring)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	w, err := clearsign.Encode(&out, signer.PrivateKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("starting clearsign: %w", err)
+	}
+	if _, err := w.Write(releaseData); err != nil {
+		return nil, fmt.Errorf("signing Release data: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("finalizing signature: %w", err)
+	}
+	return out.Bytes(), nil
+}
+
+// signReleaseDetached produces the ASCII-armored detached signature apt reads as Release.gpg, for
+// older clients that fetch Release/Release.gpg as a pair instead of the combined InRelease.
+func signReleaseDetached(releaseData []byte, keyID string, keyring openpgp.EntityList) ([]byte, error) {
+	signer, err := findSigningEntity(keyID, keyring)
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&out, signer, bytes.NewReader(releaseData), nil); err != nil {
+		return nil, fmt.Errorf("signing Release data: %w", err)
+	}
+	return out.Bytes(), nil
+}
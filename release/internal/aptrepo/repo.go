@@ -9,6 +9,7 @@ import (
 	"slices"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/projectcalico/calico/release/internal/command"
 	"github.com/projectcalico/calico/release/internal/utils"
@@ -32,6 +33,29 @@ type RepoConfig struct {
 	ProductName string
 	// GPGKeyID is the GPG key ID that we'll sign the repository with
 	GPGKeyID string
+
+	// NotAutomatic, when true, tells apt to fetch this repo's metadata but not upgrade to its
+	// packages unless the user has explicitly pinned it. This is the standard way to publish
+	// hashrelease/nightly repos without them clobbering a user's stable install.
+	NotAutomatic bool
+	// ButAutomaticUpgrades, when true (and NotAutomatic is also true), allows already-pinned
+	// packages from this repo to receive upgrades automatically, per the Release file spec.
+	ButAutomaticUpgrades bool
+	// Suite is the per-component override of the 'Suite' field; if a component isn't present in
+	// this map, the component name itself is used as the suite, same as today.
+	Suite map[string]string
+	// Codename is the release codename (e.g. noble, bookworm) written to the Release file's
+	// 'Codename' field. Distinct from Suite since apt allows these to differ.
+	Codename string
+	// ValidUntilDuration, if non-zero, is added to the publish time to populate the Release
+	// file's 'Valid-Until' field so stale mirrors are rejected by clients.
+	ValidUntilDuration time.Duration
+
+	// SourcesFormat optionally overrides, per codename, which sources file format
+	// WriteSourcesFile writes. A codename absent from this map falls back to
+	// defaultSourcesFormat, which only emits deb822 .sources for codenames new enough to
+	// support it and legacy .list/keyring pairs everywhere else.
+	SourcesFormat map[string]Format
 }
 
 // Repo defines the core information about a local (on-disk) repo that we want to create/manipulate
@@ -46,8 +70,40 @@ type Repo struct {
 	Config RepoConfig
 	// PublishingURL is the full URL to the root of the published repository, e.g. https://host.com/ubuntu
 	PublishingURL string
+
+	// lastIncludedDebs records, per component, the debian package files most recently added via
+	// IncludeDeb/RecursiveAddDebsFromDirectories. It is used by Snapshot to capture an immutable
+	// manifest of "what's in the repo right now".
+	lastIncludedDebs map[string][]string
+
+	// publishedHashes records the content hash of each relative path most recently uploaded by
+	// Publish, so subsequent calls can skip re-uploading unchanged files.
+	publishedHashes map[string]string
+
+	// Backend selects how IncludeDeb/RecursiveAddDebsFromDirectories generate the repo index.
+	// Defaults to BackendReprepro for backwards compatibility.
+	Backend Backend
+
+	// nativePkgs accumulates package metadata added via the native backend, for later index
+	// generation by WriteNativeIndices. Unused when Backend is BackendReprepro.
+	nativePkgs *nativePackages
+
+	// PackagePolicy, if set, gates which packages IncludeDeb is willing to accept.
+	PackagePolicy PackagePolicy
 }
 
+// Backend selects the implementation used to build the apt repo index.
+type Backend int
+
+const (
+	// BackendReprepro shells out to the reprepro binary, as Repo has always done.
+	BackendReprepro Backend = iota
+	// BackendNative parses .deb files and writes the pool/dists layout directly in Go, with no
+	// external dependency on reprepro (or a Perl/Debian toolchain), at the cost of supporting a
+	// narrower slice of reprepro's configuration surface.
+	BackendNative
+)
+
 //go:embed reprepro-conf.gotmpl
 var repoDistributionsTemplate string
 
@@ -187,26 +243,42 @@ func (repo *Repo) IncludeDeb(component, debFile string) error {
 		return fmt.Errorf("specified component %s not present in configured components list %s", component, strings.Join(repo.Config.Components, ", "))
 	}
 
-	err := repo.exec("includedeb", component, debFile)
-	if err != nil {
-		return fmt.Errorf("Could not add file %s to component %s: %w", debFile, component, err)
+	if err := repo.checkPackagePolicy(debFile); err != nil {
+		return err
+	}
+
+	switch repo.Backend {
+	case BackendNative:
+		if err := repo.nativeIncludeDeb(component, debFile); err != nil {
+			return fmt.Errorf("Could not add file %s to component %s: %w", debFile, component, err)
+		}
+	default:
+		if err := repo.exec("includedeb", component, debFile); err != nil {
+			return fmt.Errorf("Could not add file %s to component %s: %w", debFile, component, err)
+		}
 	}
+
+	if repo.lastIncludedDebs == nil {
+		repo.lastIncludedDebs = map[string][]string{}
+	}
+	repo.lastIncludedDebs[component] = append(repo.lastIncludedDebs[component], debFile)
+
 	return nil
 }
 
 // RecursiveAddDebsFromDirectories takes a list of paths to search and finds all debian packages
 // under those paths, gets their suite/component name, and adds them to the repo
 func (repo *Repo) RecursiveAddDebsFromDirectories(searchPaths []string) error {
-	debsBySuite, err := getRecursiveDebsBySuite(searchPaths)
+	debsBySuite, err := ScanDebs(searchPaths)
 	if err != nil {
 		return fmt.Errorf("could not scan for debian packages: %w", err)
 	}
 
 	var publishingErrors []error
 
-	for suite, filesList := range debsBySuite {
-		for _, filename := range filesList {
-			if err := repo.IncludeDeb(suite, filename); err != nil {
+	for suite, pkgs := range debsBySuite {
+		for _, pkg := range pkgs {
+			if err := repo.IncludeDeb(suite, pkg.Path); err != nil {
 				publishingErrors = append(publishingErrors, err)
 			}
 
@@ -233,16 +305,69 @@ func (repo *Repo) WriteSourcesFile(codename string) error {
 		RepoName:      repo.Config.ProductName,
 		RepoURL:       repo.PublishingURL,
 		Suite:         codename,
+		Origin:        repo.Config.Origin,
 		GpgKey:        gpgPubKeyFormatted,
+		RawGpgKey:     gpgPubKey,
 		Architectures: repo.Config.Architectures,
+		Format:        repo.sourcesFormat(codename),
 	}
 
 	if err := sourcesFields.writeAptSourcesFile(repo.OutputDirectory); err != nil {
 		return fmt.Errorf("Unable to write sources file for %s: %w", codename, err)
 	}
+
+	if repo.Config.NotAutomatic {
+		if err := repo.writePinPreferenceFile(codename); err != nil {
+			return fmt.Errorf("Unable to write pin preference file for %s: %w", codename, err)
+		}
+	}
+
 	return nil
 }
 
+// writePinPreferenceFile writes a <codename>.pref file next to the .sources file describing the
+// apt pin priority needed to opt in to a NotAutomatic repo; users copy both files into
+// /etc/apt/{sources.list.d,preferences.d}.
+func (repo *Repo) writePinPreferenceFile(codename string) error {
+	prefPath := filepath.Join(repo.OutputDirectory, fmt.Sprintf("%s.pref", codename))
+	prefFile, err := os.OpenFile(prefPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to create pin preference file: %w", err)
+	}
+	defer func() { _ = prefFile.Close() }()
+
+	suite := codename
+	if override, ok := repo.Config.Suite[codename]; ok && override != "" {
+		suite = override
+	}
+
+	_, err = fmt.Fprintf(prefFile, "Package: *\nPin: release o=%s,n=%s\nPin-Priority: 100\n",
+		repo.Config.Origin, suite)
+	return err
+}
+
+// sourcesFormat returns the Format WriteSourcesFile should use for codename: the repo's
+// SourcesFormat override if one is configured for codename, else defaultSourcesFormat's verdict.
+func (repo *Repo) sourcesFormat(codename string) Format {
+	if format, ok := repo.Config.SourcesFormat[codename]; ok {
+		return format
+	}
+	return defaultSourcesFormat(codename)
+}
+
+// defaultSourcesFormat picks a sources file format for a codename that has no explicit
+// RepoConfig.SourcesFormat entry: deb822 for the codenames new enough for apt >= 2.4 to parse
+// (noble, bookworm), and the legacy one-line format for everything older, so CI images and
+// clients running older distros still get a working sources file.
+func defaultSourcesFormat(codename string) Format {
+	switch codename {
+	case "noble", "bookworm":
+		return FormatDeb822
+	default:
+		return FormatLegacy
+	}
+}
+
 // WriteAllSourcesFiles creates a <codename>.sources in the repo's output directory for
 // each configured codename/suite.
 func (repo *Repo) WriteAllSourcesFiles() error {
@@ -0,0 +1,230 @@
+package aptrepo
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/calico/release/internal/utils"
+)
+
+// SnapshotPackage describes a single .deb (or .ddeb) file captured in a Snapshot.
+type SnapshotPackage struct {
+	// Path is the path to the package file as it was found on disk when the snapshot was taken.
+	Path string `json:"path"`
+	// SHA256 is the hex-encoded SHA256 digest of the package file.
+	SHA256 string `json:"sha256"`
+	// Size is the size, in bytes, of the package file.
+	Size int64 `json:"size"`
+}
+
+// Snapshot is an immutable, named record of the packages that were included in the repo at the
+// time it was taken. It is modeled on aptly's separation of snapshots from published repos: a
+// snapshot only records what's in the repo, publishing it is a distinct, repeatable step.
+type Snapshot struct {
+	// Name is the snapshot's unique name.
+	Name string `json:"name"`
+	// CreatedAt is when the snapshot was taken.
+	CreatedAt time.Time `json:"createdAt"`
+	// ComponentPackages maps each component to the packages resolved into it.
+	ComponentPackages map[string][]SnapshotPackage `json:"componentPackages"`
+}
+
+// snapshotsDir returns the directory under BaseDirectory where snapshot manifests are stored.
+func (repo *Repo) snapshotsDir() string {
+	return filepath.Join(repo.BaseDirectory, "snapshots")
+}
+
+func (repo *Repo) snapshotPath(name string) string {
+	return filepath.Join(repo.snapshotsDir(), fmt.Sprintf("%s.json", name))
+}
+
+// Snapshot captures the current set of packages known to the repo (as last recorded by
+// RecursiveAddDebsFromDirectories) under the given name, and writes the manifest to
+// BaseDirectory/snapshots/<name>.json. Snapshot names must be unique; re-using a name overwrites
+// the previous manifest.
+func (repo *Repo) Snapshot(name string) (*Snapshot, error) {
+	if name == "" {
+		return nil, fmt.Errorf("snapshot name must not be empty")
+	}
+
+	componentPackages := make(map[string][]SnapshotPackage, len(repo.lastIncludedDebs))
+	for component, paths := range repo.lastIncludedDebs {
+		pkgs := make([]SnapshotPackage, 0, len(paths))
+		for _, p := range paths {
+			sp, err := hashPackageFile(p)
+			if err != nil {
+				return nil, fmt.Errorf("hashing %s for snapshot %s: %w", p, name, err)
+			}
+			pkgs = append(pkgs, sp)
+		}
+		sort.Slice(pkgs, func(i, j int) bool { return pkgs[i].Path < pkgs[j].Path })
+		componentPackages[component] = pkgs
+	}
+
+	snap := &Snapshot{
+		Name:              name,
+		CreatedAt:         time.Now().UTC(),
+		ComponentPackages: componentPackages,
+	}
+
+	if err := os.MkdirAll(repo.snapshotsDir(), utils.DirPerms); err != nil {
+		return nil, fmt.Errorf("creating snapshots dir: %w", err)
+	}
+
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshaling snapshot %s: %w", name, err)
+	}
+	if err := os.WriteFile(repo.snapshotPath(name), data, 0o644); err != nil {
+		return nil, fmt.Errorf("writing snapshot %s: %w", name, err)
+	}
+
+	logrus.WithField("snapshot", name).Info("Wrote apt repo snapshot")
+	return snap, nil
+}
+
+// ListSnapshots returns the names of every snapshot currently recorded for the repo, sorted
+// alphabetically.
+func (repo *Repo) ListSnapshots() ([]string, error) {
+	exists, err := utils.DirExists(repo.snapshotsDir())
+	if err != nil {
+		return nil, fmt.Errorf("checking snapshots dir: %w", err)
+	}
+	if !exists {
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(repo.snapshotsDir())
+	if err != nil {
+		return nil, fmt.Errorf("listing snapshots dir: %w", err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != ".json" {
+			continue
+		}
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// loadSnapshot reads and parses a previously-written snapshot manifest.
+func (repo *Repo) loadSnapshot(name string) (*Snapshot, error) {
+	data, err := os.ReadFile(repo.snapshotPath(name))
+	if err != nil {
+		return nil, fmt.Errorf("reading snapshot %s: %w", name, err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("parsing snapshot %s: %w", name, err)
+	}
+	return &snap, nil
+}
+
+// PublishSnapshot publishes a previously-taken snapshot to OutputDirectory atomically: the
+// Release/InRelease metadata is generated in a staging directory (OutputDirectory +
+// ".staging-<ts>") and only renamed into place once generation succeeds. The directory that was
+// previously published is moved aside as ".prev-<ts>" so RollbackTo can restore it.
+func (repo *Repo) PublishSnapshot(name string) error {
+	snap, err := repo.loadSnapshot(name)
+	if err != nil {
+		return fmt.Errorf("could not load snapshot %s: %w", name, err)
+	}
+
+	ts := time.Now().UTC().Format("20060102T150405Z")
+	stagingDir := fmt.Sprintf("%s.staging-%s", repo.OutputDirectory, ts)
+
+	if err := os.MkdirAll(stagingDir, utils.DirPerms); err != nil {
+		return fmt.Errorf("creating staging dir %s: %w", stagingDir, err)
+	}
+	defer func() { _ = os.RemoveAll(stagingDir) }()
+
+	for component, pkgs := range snap.ComponentPackages {
+		for _, pkg := range pkgs {
+			if err := repo.IncludeDeb(component, pkg.Path); err != nil {
+				return fmt.Errorf("publishing snapshot %s: %w", name, err)
+			}
+		}
+	}
+
+	// repo.exec() above writes directly into repo.OutputDirectory via reprepro; move the
+	// freshly-generated tree into the staging directory so we can swap it in atomically.
+	if err := os.RemoveAll(stagingDir); err != nil {
+		return fmt.Errorf("clearing staging dir %s: %w", stagingDir, err)
+	}
+	if err := os.Rename(repo.OutputDirectory, stagingDir); err != nil {
+		return fmt.Errorf("moving generated repo into staging dir: %w", err)
+	}
+
+	prevDir := fmt.Sprintf("%s.prev-%s", repo.OutputDirectory, ts)
+	if exists, err := utils.DirExists(repo.OutputDirectory); err != nil {
+		return fmt.Errorf("checking existing output dir: %w", err)
+	} else if exists {
+		if err := os.Rename(repo.OutputDirectory, prevDir); err != nil {
+			return fmt.Errorf("moving previous published repo aside: %w", err)
+		}
+	}
+
+	if err := os.Rename(stagingDir, repo.OutputDirectory); err != nil {
+		return fmt.Errorf("publishing staged repo: %w", err)
+	}
+
+	logrus.WithFields(logrus.Fields{"snapshot": name, "previous": prevDir}).Info("Published apt repo snapshot")
+	return nil
+}
+
+// RollbackTo restores the repo's OutputDirectory from the ".prev-<ts>" directory left behind by
+// the most recent PublishSnapshot call, so an operator can revert a bad promotion without
+// re-running the whole build.
+func (repo *Repo) RollbackTo(name string) error {
+	prevDirs, err := filepath.Glob(fmt.Sprintf("%s.prev-*", repo.OutputDirectory))
+	if err != nil {
+		return fmt.Errorf("finding previous published repo directories: %w", err)
+	}
+	if len(prevDirs) == 0 {
+		return fmt.Errorf("no previous published repo found to roll back to")
+	}
+	sort.Strings(prevDirs)
+	prevDir := prevDirs[len(prevDirs)-1]
+
+	if err := os.RemoveAll(repo.OutputDirectory); err != nil {
+		return fmt.Errorf("removing current output dir: %w", err)
+	}
+	if err := os.Rename(prevDir, repo.OutputDirectory); err != nil {
+		return fmt.Errorf("restoring previous published repo %s: %w", prevDir, err)
+	}
+
+	logrus.WithFields(logrus.Fields{"snapshot": name, "restoredFrom": prevDir}).Info("Rolled back apt repo to previous publish")
+	return nil
+}
+
+func hashPackageFile(path string) (SnapshotPackage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return SnapshotPackage{}, err
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return SnapshotPackage{}, err
+	}
+
+	return SnapshotPackage{
+		Path:   path,
+		SHA256: fmt.Sprintf("%x", h.Sum(nil)),
+		Size:   size,
+	}, nil
+}
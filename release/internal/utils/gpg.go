@@ -35,3 +35,18 @@ func GetGPGPubKey(gpgKeyID string) (string, error) {
 	}
 	return string(gpgOut), nil
 }
+
+// GetGPGSecretKey takes a GPG key ID and fetches the ascii-armored secret key, for callers that
+// need to sign with the key directly (e.g. via openpgp) rather than shelling out to `gpg` for the
+// actual signing step.
+func GetGPGSecretKey(gpgKeyID string) (string, error) {
+	logrus.Debugf("Getting ascii-armored secret key for GPG key %s", gpgKeyID)
+
+	cmdArgs := []string{"--armor", "--export-secret-keys", gpgKeyID}
+	logrus.Debugf("running gpg with args %s", strings.Join(cmdArgs, " "))
+	gpgOut, err := command.Run("gpg", cmdArgs)
+	if err != nil {
+		return "", fmt.Errorf("exporting gpg secret key: %w", err)
+	}
+	return string(gpgOut), nil
+}
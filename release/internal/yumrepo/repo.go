@@ -0,0 +1,235 @@
+// Package yumrepo contains functionality for creating and managing yum/dnf repositories. It
+// mirrors the shape of release/internal/aptrepo so the release tooling can drive both package
+// formats through a consistent API.
+package yumrepo
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/calico/release/internal/command"
+	"github.com/projectcalico/calico/release/internal/utils"
+)
+
+// RepoConfig is the information we'll use to generate the repo's metadata and .repo client files.
+type RepoConfig struct {
+	// Architectures is the list of architectures we'll publish, e.g. x86_64, aarch64.
+	Architectures []string
+	// Components is the list of dist tags we intend to publish, e.g. el8, el9.
+	Components []string
+	// ProductName is the full name of our product that will show in the description of the repo.
+	ProductName string
+	// GPGKeyID is the GPG key ID that we'll sign packages and repomd.xml with.
+	GPGKeyID string
+}
+
+// Repo defines the core information about a local (on-disk) yum repo that we want to create/manipulate.
+type Repo struct {
+	// TempDir is where we're going to store our files while we do our generation.
+	TempDir string
+	// BaseDirectory is the absolute path to the repo base (where our configs are stored).
+	BaseDirectory string
+	// OutputDirectory is the absolute path to the output directory, where the repodata/ and
+	// package files will be stored.
+	OutputDirectory string
+	// Config is the RepoConfig object representing the information about the repo we'll be publishing.
+	Config RepoConfig
+	// PublishingURL is the full URL to the root of the published repository.
+	PublishingURL string
+
+	lastIncludedRPMs map[string][]string
+}
+
+//go:embed yum-repo.gotmpl
+var yumRepoTemplate string
+
+// NewRepo creates a new Repo instance with the appropriate fields populated.
+func NewRepo(tempDir, outputDir string, repoConfig RepoConfig, url string) (*Repo, error) {
+	repo := Repo{
+		TempDir:         tempDir,
+		BaseDirectory:   filepath.Join(tempDir, "_yum_repo_conf"),
+		OutputDirectory: outputDir,
+		Config:          repoConfig,
+		PublishingURL:   url,
+	}
+	return &repo, nil
+}
+
+// PrepareForBuild sets up the configured paths to be ready to build a yum repo.
+func (repo *Repo) PrepareForBuild() error {
+	if err := os.RemoveAll(repo.BaseDirectory); err != nil {
+		return fmt.Errorf("could not clean repo base directory %s: %w", repo.BaseDirectory, err)
+	}
+	if err := os.RemoveAll(repo.OutputDirectory); err != nil {
+		return fmt.Errorf("could not clean repo output directory %s: %w", repo.OutputDirectory, err)
+	}
+	return nil
+}
+
+// WriteRepoConfig generates and writes the .repo file for every configured component/dist tag.
+func (repo *Repo) WriteRepoConfig() error {
+	if err := os.MkdirAll(repo.BaseDirectory, utils.DirPerms); err != nil {
+		return fmt.Errorf("failed to create config dir: %w", err)
+	}
+
+	funcMap := template.FuncMap{"join": strings.Join}
+	tmpl, err := template.New("yum/repo").Funcs(funcMap).Parse(yumRepoTemplate)
+	if err != nil {
+		return fmt.Errorf("failed to parse yum repo template: %w", err)
+	}
+
+	var errs []error
+	for _, dist := range repo.Config.Components {
+		repoFilePath := filepath.Join(repo.BaseDirectory, fmt.Sprintf("%s.repo", dist))
+		f, err := os.OpenFile(repoFilePath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("creating .repo file for %s: %w", dist, err))
+			continue
+		}
+
+		data := yumRepoData{
+			RepoName:      repo.Config.ProductName,
+			RepoURL:       fmt.Sprintf("%s/%s", repo.PublishingURL, dist),
+			Dist:          dist,
+			GPGKeyURL:     fmt.Sprintf("%s/RPM-GPG-KEY-%s", repo.PublishingURL, repo.Config.GPGKeyID),
+			Architectures: repo.Config.Architectures,
+		}
+		if err := tmpl.Execute(f, data); err != nil {
+			errs = append(errs, fmt.Errorf("writing .repo file for %s: %w", dist, err))
+		}
+		_ = f.Close()
+	}
+	return errors.Join(errs...)
+}
+
+type yumRepoData struct {
+	RepoName      string
+	RepoURL       string
+	Dist          string
+	GPGKeyURL     string
+	Architectures []string
+}
+
+// IncludeRPM adds a specified RPM file to the given component (dist tag) in the repo by copying
+// it into the output directory's per-dist package directory.
+func (repo *Repo) IncludeRPM(component, rpmFile string) error {
+	if !slices.Contains(repo.Config.Components, component) {
+		return fmt.Errorf("specified component %s not present in configured components list %s", component, strings.Join(repo.Config.Components, ", "))
+	}
+
+	destDir := filepath.Join(repo.OutputDirectory, component, "Packages")
+	if err := os.MkdirAll(destDir, utils.DirPerms); err != nil {
+		return fmt.Errorf("creating package dir %s: %w", destDir, err)
+	}
+	if err := utils.CopyFile(rpmFile, filepath.Join(destDir, filepath.Base(rpmFile))); err != nil {
+		return fmt.Errorf("copying %s into %s: %w", rpmFile, destDir, err)
+	}
+
+	if repo.lastIncludedRPMs == nil {
+		repo.lastIncludedRPMs = map[string][]string{}
+	}
+	repo.lastIncludedRPMs[component] = append(repo.lastIncludedRPMs[component], rpmFile)
+
+	return nil
+}
+
+// RecursiveAddRPMsFromDirectories finds all RPM packages under the given search paths, determines
+// their dist tag, and adds them to the repo.
+func (repo *Repo) RecursiveAddRPMsFromDirectories(searchPaths []string) error {
+	rpmsByDist, err := getRecursiveRPMsByDist(searchPaths)
+	if err != nil {
+		return fmt.Errorf("could not scan for rpm packages: %w", err)
+	}
+
+	var errs []error
+	for dist, files := range rpmsByDist {
+		for _, f := range files {
+			if err := repo.IncludeRPM(dist, f); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// createRepoData invokes createrepo_c to (re)generate repodata/ for every component directory
+// that has had packages added, then signs repomd.xml with the configured GPG key.
+func (repo *Repo) createRepoData() error {
+	var errs []error
+	for _, dist := range repo.Config.Components {
+		distDir := filepath.Join(repo.OutputDirectory, dist)
+		if exists, err := utils.DirExists(distDir); err != nil {
+			errs = append(errs, err)
+			continue
+		} else if !exists {
+			continue
+		}
+
+		if _, err := command.Run("createrepo_c", []string{distDir}); err != nil {
+			errs = append(errs, fmt.Errorf("running createrepo_c for %s: %w", dist, err))
+			continue
+		}
+
+		repomdPath := filepath.Join(distDir, "repodata", "repomd.xml")
+		if _, err := command.Run("gpg", []string{"--detach-sign", "--armor", "--local-user", repo.Config.GPGKeyID, repomdPath}); err != nil {
+			errs = append(errs, fmt.Errorf("signing repomd.xml for %s: %w", dist, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// WriteAllSourcesFiles creates a <dist>.repo in the repo's base directory for each configured
+// dist tag, for users to drop into /etc/yum.repos.d/.
+func (repo *Repo) WriteAllSourcesFiles() error {
+	return repo.WriteRepoConfig()
+}
+
+func getRecursiveRPMsByDist(searchPaths []string) (map[string][]string, error) {
+	rpmsByDist := make(map[string][]string)
+	for _, searchPath := range searchPaths {
+		logrus.Infof("Scanning for RPM packages in %s", searchPath)
+		err := filepath.WalkDir(searchPath, func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				if d.Name() == ".git" {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if strings.HasSuffix(path, ".rpm") {
+				dist, err := distTagFromRPMFilename(filepath.Base(path))
+				if err != nil {
+					return fmt.Errorf("determining dist tag for %s: %w", path, err)
+				}
+				rpmsByDist[dist] = append(rpmsByDist[dist], path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walking directory %s: %w", searchPath, err)
+		}
+	}
+	return rpmsByDist, nil
+}
+
+// distTagFromRPMFilename extracts the dist tag (e.g. "el8", "el9") from an RPM's release field as
+// encoded in its filename, e.g. "calico-felix-3.28.0-1.el9.x86_64.rpm" -> "el9".
+func distTagFromRPMFilename(filename string) (string, error) {
+	parts := strings.Split(strings.TrimSuffix(filename, ".rpm"), ".")
+	for i := len(parts) - 1; i >= 0; i-- {
+		if strings.HasPrefix(parts[i], "el") {
+			return parts[i], nil
+		}
+	}
+	return "", fmt.Errorf("rpm filename %s does not contain a recognizable dist tag", filename)
+}
@@ -0,0 +1,111 @@
+package yumrepo
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/projectcalico/calico/release/internal/utils"
+)
+
+// Snapshot is the yum-side equivalent of aptrepo.Snapshot: an immutable record of which RPMs were
+// included in the repo, by dist tag, at the time the snapshot was taken.
+type Snapshot struct {
+	Name              string                     `json:"name"`
+	CreatedAt         time.Time                  `json:"createdAt"`
+	ComponentPackages map[string][]SnapshotEntry `json:"componentPackages"`
+}
+
+// SnapshotEntry describes a single RPM captured in a Snapshot.
+type SnapshotEntry struct {
+	Path   string `json:"path"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+func (repo *Repo) snapshotsDir() string {
+	return filepath.Join(repo.BaseDirectory, "snapshots")
+}
+
+func (repo *Repo) snapshotPath(name string) string {
+	return filepath.Join(repo.snapshotsDir(), fmt.Sprintf("%s.json", name))
+}
+
+// Snapshot captures the current set of RPMs known to the repo under the given name.
+func (repo *Repo) Snapshot(name string) (*Snapshot, error) {
+	if name == "" {
+		return nil, fmt.Errorf("snapshot name must not be empty")
+	}
+
+	componentPackages := make(map[string][]SnapshotEntry, len(repo.lastIncludedRPMs))
+	for component, paths := range repo.lastIncludedRPMs {
+		entries := make([]SnapshotEntry, 0, len(paths))
+		for _, p := range paths {
+			f, err := os.Open(p)
+			if err != nil {
+				return nil, fmt.Errorf("opening %s for snapshot %s: %w", p, name, err)
+			}
+			h := sha256.New()
+			size, err := io.Copy(h, f)
+			_ = f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("hashing %s for snapshot %s: %w", p, name, err)
+			}
+			entries = append(entries, SnapshotEntry{Path: p, SHA256: fmt.Sprintf("%x", h.Sum(nil)), Size: size})
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Path < entries[j].Path })
+		componentPackages[component] = entries
+	}
+
+	snap := &Snapshot{Name: name, CreatedAt: time.Now().UTC(), ComponentPackages: componentPackages}
+
+	if err := os.MkdirAll(repo.snapshotsDir(), utils.DirPerms); err != nil {
+		return nil, fmt.Errorf("creating snapshots dir: %w", err)
+	}
+	data, err := json.MarshalIndent(snap, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("marshaling snapshot %s: %w", name, err)
+	}
+	if err := os.WriteFile(repo.snapshotPath(name), data, 0o644); err != nil {
+		return nil, fmt.Errorf("writing snapshot %s: %w", name, err)
+	}
+
+	logrus.WithField("snapshot", name).Info("Wrote yum repo snapshot")
+	return snap, nil
+}
+
+// PublishSnapshot copies every RPM recorded in a previously-taken snapshot into OutputDirectory
+// and regenerates repodata there in place. It uses no staging directory, does not swap
+// directories, and keeps no previous copy of the repo to roll back to.
+func (repo *Repo) PublishSnapshot(name string) error {
+	data, err := os.ReadFile(repo.snapshotPath(name))
+	if err != nil {
+		return fmt.Errorf("reading snapshot %s: %w", name, err)
+	}
+	var snap Snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("parsing snapshot %s: %w", name, err)
+	}
+
+	for component, entries := range snap.ComponentPackages {
+		for _, e := range entries {
+			if err := repo.IncludeRPM(component, e.Path); err != nil {
+				return fmt.Errorf("publishing snapshot %s: %w", name, err)
+			}
+		}
+	}
+
+	if err := repo.createRepoData(); err != nil {
+		return fmt.Errorf("generating repodata for snapshot %s: %w", name, err)
+	}
+
+	logrus.WithField("snapshot", name).Info("Published yum repo snapshot")
+	return nil
+}